@@ -0,0 +1,45 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ad/ton-site-ha/config"
+	rldphttp "github.com/ad/ton-site-ha/internal/rldphttp"
+)
+
+// addressCommand prints the site address and ADNL id of the configured key,
+// or of a key in any supported format read from stdin with `address -`
+func addressCommand(args []string) error {
+	var key ed25519.PrivateKey
+	if len(args) > 1 && args[1] == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read key: %w", err)
+		}
+
+		if key, err = config.ParseKey(string(data)); err != nil {
+			return fmt.Errorf("failed to parse key: %w", err)
+		}
+	} else {
+		conf, err := config.LoadConfig(args, version)
+		if err != nil {
+			return err
+		}
+
+		if key, err = config.ParseKey(conf.Key); err != nil {
+			return err
+		}
+	}
+
+	addr, err := rldphttp.ADNLAddressFromPublicKey(key.Public().(ed25519.PublicKey))
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("address:", addr.String()+".adnl")
+	fmt.Println("id:", addr.Hex())
+	return nil
+}
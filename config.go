@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/ad/ton-site-ha/config"
+)
+
+func configCommand(args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: config check|print|schema")
+	}
+
+	switch args[1] {
+	case "check":
+		if err := config.Check(args[1:], version); err != nil {
+			return err
+		}
+
+		fmt.Println("config is valid")
+		return nil
+	case "print":
+		// only parsed, the key store is not touched and secrets are never shown
+		conf, err := config.Parse(args[1:], version)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, s := range conf.Settings() {
+			value, err := json.Marshal(s.Value)
+			if err != nil {
+				return err
+			}
+			if s.Secret && s.Value != "" {
+				value = []byte("<redacted>")
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\n", s.Name, value, s.Source)
+		}
+		return w.Flush()
+	case "schema":
+		// the options and schema sections of config.json, generated from config.Config
+		data, err := config.AddonSchema()
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(data))
+		return nil
+	}
+
+	return fmt.Errorf("unknown config command %q", args[1])
+}
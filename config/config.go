@@ -3,44 +3,220 @@ package config
 import (
 	"crypto/ed25519"
 	"encoding/hex"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"io"
-	"os"
+	"io/fs"
+	"time"
 
 	_ "github.com/joho/godotenv/autoload"
 )
 
-const ConfigFileName = "/data/options.json"
+// ConfigFileName is the options file written by the Supervisor, it is watched for changes
+var ConfigFileName = "/data/options.json"
+
+// DefaultKeyStore keeps the generated site key next to the add-on options
+const DefaultKeyStore = "/data/site.key"
+
+// DefaultDHTKeyStore keeps the key of the DHT client, so its routing state survives restarts
+const DefaultDHTKeyStore = "/data/dht.key"
+
+// DefaultDHTNodesCache keeps recently used DHT nodes to bootstrap the routing table from
+const DefaultDHTNodesCache = "/data/dht-nodes.json"
+
+// DefaultStatusListen keeps the status page on the host itself, LISTEN_PORT is public
+const DefaultStatusListen = "127.0.0.1:9057"
+
+// DefaultContentDir is in the Home Assistant share folder, its files are served over the embedded site
+const DefaultContentDir = "/share/ton-site"
+
+// DefaultCacheControl lets static files be cached for a day, pages are checked on every visit
+const DefaultCacheControl = "/static/=public, max-age=86400; /=no-cache"
+
+// DefaultResponseCacheMB is small enough for a Pi Zero and holds the pages of a typical site
+const DefaultResponseCacheMB = 8
+
+// DefaultContentSecurityPolicy fits the built-in site: its own styles, scripts and images, no framing
+const DefaultContentSecurityPolicy = "default-src 'self'; img-src 'self' data:; style-src 'self' 'unsafe-inline'; " +
+	"frame-ancestors 'none'; base-uri 'self'; form-action 'self'"
+
+// DefaultReferrerPolicy keeps visited .ton addresses from other sites
+const DefaultReferrerPolicy = "no-referrer"
+
+// DefaultPermissionsPolicy turns off the browser features a site has no use for
+const DefaultPermissionsPolicy = "camera=(), microphone=(), geolocation=(), payment=(), usb=()"
+
+// DefaultPreviousKeyStore keeps the key replaced by `key rotate` during the overlap period
+const DefaultPreviousKeyStore = "/data/site.key.previous"
 
 // Config ...
 type Config struct {
 	Version    string `json:"VERSION"`
-	Key        string `json:"KEY"`
-	ListenHost string `json:"LISTEN_HOST"`
-	ListenPort string `json:"LISTEN_PORT"`
-	Debug      bool   `json:"DEBUG"`
+	Key        string `json:"KEY" schema:"password?"`
+	ListenHost string `json:"LISTEN_HOST" schema:"str"`
+	ListenPort string `json:"LISTEN_PORT" schema:"str"`
+	Debug      bool   `json:"DEBUG" schema:"bool" reload:"live"`
+
+	// DevMode parses templates again when they change, instead of once on start and reload
+	DevMode bool `json:"DEV_MODE" schema:"bool" reload:"live"`
+
+	DomainNFTAddress string `json:"DOMAIN_NFT_ADDRESS" schema:"str?"`
+	StatusPage       bool   `json:"STATUS_PAGE" schema:"bool"`
+
+	// StatusListen is the TCP address of the status page, it shows the DNS payload and transfer link
+	// so it binds to loopback unless set to a reachable address
+	StatusListen string `json:"STATUS_LISTEN" schema:"str?"`
+	// Metrics serves the response cache metrics under /metrics on STATUS_LISTEN
+	Metrics bool `json:"METRICS" schema:"bool"`
+
+	ContentDir string `json:"CONTENT_DIR" schema:"str?" reload:"live"`
+
+	// MediaDir is published under /media/ to anyone on the TON network, camera recordings included
+	// if it is the Home Assistant media folder, so nothing is served until it is set
+	MediaDir string `json:"MEDIA_DIR" schema:"str?" reload:"live"`
+
+	// CacheControl sets Cache-Control by path prefix, like `/static/=public, max-age=86400; /=no-cache`
+	CacheControl string `json:"CACHE_CONTROL" schema:"str?" reload:"live"`
+
+	// Routes lay out the site, matched in order, the whole site is served at / without them
+	Routes Routes `json:"ROUTES" schema:"routes" reload:"live"`
+
+	// Hosts give each .ton domain pointing at the site its own content directory,
+	// DefaultHost is served to the .adnl address and to hosts not listed
+	Hosts             []Host `json:"HOSTS" schema:"hosts" reload:"live"`
+	DefaultHost       string `json:"DEFAULT_HOST" schema:"str?" reload:"live"`
+	CanonicalRedirect bool   `json:"CANONICAL_REDIRECT" schema:"bool" reload:"live"`
+
+	// ResponseCacheMB bounds the memory of rendered and compressed responses kept, 0 turns it off
+	ResponseCacheMB int `json:"RESPONSE_CACHE_MB" schema:"int(0,)" reload:"live"`
+
+	// ContentSecurityPolicy, ReferrerPolicy and PermissionsPolicy are sent with every response,
+	// an empty one is left out
+	ContentSecurityPolicy string `json:"CONTENT_SECURITY_POLICY" schema:"str?" reload:"live"`
+	ReferrerPolicy        string `json:"REFERRER_POLICY" schema:"str?" reload:"live"`
+	PermissionsPolicy     string `json:"PERMISSIONS_POLICY" schema:"str?" reload:"live"`
+
+	KeyFile     string `json:"KEY_FILE" schema:"str?"`
+	Mnemonic    string `json:"MNEMONIC" schema:"password?"`
+	GenerateKey bool   `json:"GENERATE_KEY" schema:"bool"`
+	KeyStore    string `json:"KEY_STORE" schema:"str?"`
+
+	// KeyPassphrase is only taken from env, options.json ends up in backups
+	KeyPassphrase     string `json:"-"`
+	KeyPassphraseFile string `json:"KEY_PASSPHRASE_FILE" schema:"str?"`
+
+	PreviousKey      string `json:"PREVIOUS_KEY" schema:"password?"`
+	PreviousKeyStore string `json:"PREVIOUS_KEY_STORE" schema:"str?"`
+	RotationUntil    string `json:"ROTATION_UNTIL" schema:"str?"`
+	RotationMode     string `json:"ROTATION_MODE" schema:"list(redirect|mirror)"`
+
+	// RotationEnd is when the previous key is retired, zero if it is never
+	RotationEnd time.Time `json:"-"`
+
+	DHTSharedGateway bool   `json:"DHT_SHARED_GATEWAY" schema:"bool"`
+	DHTKeyStore      string `json:"DHT_KEY_STORE" schema:"str?"`
+	DHTNodesCache    string `json:"DHT_NODES_CACHE" schema:"str?"`
+
+	// sources keeps where each option set outside of Defaults came from
+	sources map[string]string
 }
 
 func InitConfig(args []string, version string) (*Config, error) {
-	var config = &Config{
+	config, err := Parse(args, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := config.resolveKey(); err != nil {
+		return nil, err
+	}
+
+	if err := config.resolvePreviousKey(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// LoadConfig resolves the keys like InitConfig but never generates one, commands that only
+// read the key must not create a new site address as a side effect
+func LoadConfig(args []string, version string) (*Config, error) {
+	config, err := Parse(args, version)
+	if err != nil {
+		return nil, err
+	}
+
+	config.GenerateKey = false
+	if err := config.resolveKey(); err != nil {
+		return nil, err
+	}
+
+	if err := config.resolvePreviousKey(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// Defaults returns the config used when an option is not set anywhere
+func Defaults(version string) *Config {
+	return &Config{
 		Version:    version,
 		Key:        "",
 		ListenHost: "",
 		ListenPort: "9056",
 
 		Debug: false,
+
+		StatusPage:   true,
+		StatusListen: DefaultStatusListen,
+
+		ContentDir:   DefaultContentDir,
+		CacheControl: DefaultCacheControl,
+
+		Routes:          Routes{},
+		Hosts:           []Host{},
+		ResponseCacheMB: DefaultResponseCacheMB,
+
+		ContentSecurityPolicy: DefaultContentSecurityPolicy,
+		ReferrerPolicy:        DefaultReferrerPolicy,
+		PermissionsPolicy:     DefaultPermissionsPolicy,
+
+		GenerateKey: true,
+		KeyStore:    DefaultKeyStore,
+
+		PreviousKeyStore: DefaultPreviousKeyStore,
+		RotationMode:     RotationRedirect,
+
+		DHTKeyStore:   DefaultDHTKeyStore,
+		DHTNodesCache: DefaultDHTNodesCache,
+	}
+}
+
+// Parse reads the config from file, env and flags without resolving the site key,
+// every problem found is reported at once as Errors
+func Parse(args []string, version string) (*Config, error) {
+	var config = Defaults(version)
+
+	// problems with single options are reported together with the ones Validate finds
+	var errs Errors
+	if err := config.readFile(ConfigFileName); err != nil && !errors.As(err, &errs) {
+		return nil, err
 	}
 
-	if _, err := os.Stat(ConfigFileName); err == nil {
-		jsonFile, err := os.Open(ConfigFileName)
-		if err == nil {
-			byteValue, _ := io.ReadAll(jsonFile)
-			if err = json.Unmarshal(byteValue, &config); err != nil {
-				fmt.Printf("error on unmarshal config from file %s\n", err.Error())
-			}
+	envBool := func(key string, defaultVal bool) bool {
+		val, err := lookupEnvOrBool(key, defaultVal)
+		if err != nil {
+			errs.add(key, "%s", err)
 		}
+		return val
+	}
+	envInt := func(key string, defaultVal int) int {
+		val, err := lookupEnvOrInt(key, defaultVal)
+		if err != nil {
+			errs.add(key, "%s", err)
+		}
+		return val
 	}
 
 	// env vars always override file values; flags override env vars
@@ -48,24 +224,141 @@ func InitConfig(args []string, version string) (*Config, error) {
 	flags.StringVar(&config.Key, "key", lookupEnvOrString("KEY", config.Key), "KEY")
 	flags.StringVar(&config.ListenHost, "listenHost", lookupEnvOrString("LISTEN_HOST", config.ListenHost), "LISTEN_HOST")
 	flags.StringVar(&config.ListenPort, "listenPort", lookupEnvOrString("LISTEN_PORT", config.ListenPort), "LISTEN_PORT")
-	flags.BoolVar(&config.Debug, "debug", lookupEnvOrBool("DEBUG", config.Debug), "Debug")
+	flags.BoolVar(&config.Debug, "debug", envBool("DEBUG", config.Debug), "DEBUG")
+	flags.BoolVar(&config.DevMode, "devMode", envBool("DEV_MODE", config.DevMode), "DEV_MODE")
+	flags.StringVar(&config.DomainNFTAddress, "domainNftAddress", lookupEnvOrString("DOMAIN_NFT_ADDRESS", config.DomainNFTAddress), "DOMAIN_NFT_ADDRESS")
+	flags.BoolVar(&config.StatusPage, "statusPage", envBool("STATUS_PAGE", config.StatusPage), "STATUS_PAGE")
+	flags.StringVar(&config.StatusListen, "statusListen", lookupEnvOrString("STATUS_LISTEN", config.StatusListen), "STATUS_LISTEN")
+	flags.BoolVar(&config.Metrics, "metrics", envBool("METRICS", config.Metrics), "METRICS")
+	flags.StringVar(&config.ContentDir, "contentDir", lookupEnvOrString("CONTENT_DIR", config.ContentDir), "CONTENT_DIR")
+	flags.StringVar(&config.MediaDir, "mediaDir", lookupEnvOrString("MEDIA_DIR", config.MediaDir), "MEDIA_DIR")
+	flags.StringVar(&config.CacheControl, "cacheControl", lookupEnvOrString("CACHE_CONTROL", config.CacheControl), "CACHE_CONTROL")
+	for name, value := range map[string]any{"ROUTES": &config.Routes, "HOSTS": &config.Hosts} {
+		if err := lookupEnvJSON(name, value); err != nil {
+			errs.add(name, "%s", err)
+		}
+	}
+	flags.Var(jsonFlag{&config.Routes}, "routes", "ROUTES")
+	flags.Var(jsonFlag{&config.Hosts}, "hosts", "HOSTS")
+	flags.StringVar(&config.DefaultHost, "defaultHost", lookupEnvOrString("DEFAULT_HOST", config.DefaultHost), "DEFAULT_HOST")
+	flags.BoolVar(&config.CanonicalRedirect, "canonicalRedirect", envBool("CANONICAL_REDIRECT", config.CanonicalRedirect), "CANONICAL_REDIRECT")
+	flags.IntVar(&config.ResponseCacheMB, "responseCacheMb", envInt("RESPONSE_CACHE_MB", config.ResponseCacheMB), "RESPONSE_CACHE_MB")
+	flags.StringVar(&config.ContentSecurityPolicy, "contentSecurityPolicy", lookupEnvOrString("CONTENT_SECURITY_POLICY", config.ContentSecurityPolicy), "CONTENT_SECURITY_POLICY")
+	flags.StringVar(&config.ReferrerPolicy, "referrerPolicy", lookupEnvOrString("REFERRER_POLICY", config.ReferrerPolicy), "REFERRER_POLICY")
+	flags.StringVar(&config.PermissionsPolicy, "permissionsPolicy", lookupEnvOrString("PERMISSIONS_POLICY", config.PermissionsPolicy), "PERMISSIONS_POLICY")
+	flags.StringVar(&config.KeyFile, "keyFile", lookupEnvOrString("KEY_FILE", config.KeyFile), "KEY_FILE")
+	flags.StringVar(&config.Mnemonic, "mnemonic", lookupEnvOrString("MNEMONIC", config.Mnemonic), "MNEMONIC")
+	flags.StringVar(&config.KeyPassphraseFile, "keyPassphraseFile", lookupEnvOrString("KEY_PASSPHRASE_FILE", config.KeyPassphraseFile), "KEY_PASSPHRASE_FILE")
+	flags.BoolVar(&config.GenerateKey, "generateKey", envBool("GENERATE_KEY", config.GenerateKey), "GENERATE_KEY")
+	flags.StringVar(&config.KeyStore, "keyStore", lookupEnvOrString("KEY_STORE", config.KeyStore), "KEY_STORE")
+	flags.StringVar(&config.PreviousKey, "previousKey", lookupEnvOrString("PREVIOUS_KEY", config.PreviousKey), "PREVIOUS_KEY")
+	flags.StringVar(&config.PreviousKeyStore, "previousKeyStore", lookupEnvOrString("PREVIOUS_KEY_STORE", config.PreviousKeyStore), "PREVIOUS_KEY_STORE")
+	flags.StringVar(&config.RotationUntil, "rotationUntil", lookupEnvOrString("ROTATION_UNTIL", config.RotationUntil), "ROTATION_UNTIL")
+	flags.StringVar(&config.RotationMode, "rotationMode", lookupEnvOrString("ROTATION_MODE", config.RotationMode), "ROTATION_MODE")
+	flags.BoolVar(&config.DHTSharedGateway, "dhtSharedGateway", envBool("DHT_SHARED_GATEWAY", config.DHTSharedGateway), "DHT_SHARED_GATEWAY")
+	flags.StringVar(&config.DHTKeyStore, "dhtKeyStore", lookupEnvOrString("DHT_KEY_STORE", config.DHTKeyStore), "DHT_KEY_STORE")
+	flags.StringVar(&config.DHTNodesCache, "dhtNodesCache", lookupEnvOrString("DHT_NODES_CACHE", config.DHTNodesCache), "DHT_NODES_CACHE")
 
 	if err := flags.Parse(args[1:]); err != nil {
 		return nil, err
 	}
+	config.trackSources(flags)
 
-	if config.Key == "" {
-		key, errGenerateKey := generateKey()
-		if errGenerateKey == nil {
-			fmt.Printf("you can use this one generated for you: %s\n", key)
-		}
-		return nil, fmt.Errorf("%s", "key not found in config")
+	config.KeyPassphrase = lookupEnvOrString("KEY_PASSPHRASE", "")
+
+	var invalid Errors
+	if errors.As(config.Validate(), &invalid) {
+		errs = append(errs, invalid...)
+	}
+	if err := errs.err(); err != nil {
+		return nil, err
 	}
 
 	return config, nil
 }
 
-func generateKey() (string, error) {
+// ErrNoKey is returned when no key is set and none may be generated
+var ErrNoKey = errors.New("no key configured, set KEY, KEY_FILE or MNEMONIC, or enable GENERATE_KEY to create one on start")
+
+// resolveKey takes the key from KEY, KEY_FILE, MNEMONIC or the key store, generating and
+// persisting a new key there on first run if allowed, and normalizes it to a hex seed
+func (c *Config) resolveKey() (err error) {
+	if c.Key, err = resolveSecret(c.Key); err != nil {
+		return err
+	}
+
+	if c.Key == "" && c.KeyFile != "" {
+		if c.Key, err = readSecretFile(c.KeyFile); err != nil {
+			return err
+		}
+	}
+
+	if c.Key == "" && c.Mnemonic != "" {
+		mnemonic, err := resolveSecret(c.Mnemonic)
+		if err != nil {
+			return err
+		}
+
+		seed, err := MnemonicToSeed(mnemonic)
+		if err != nil {
+			return err
+		}
+		c.Key = hex.EncodeToString(seed)
+	}
+
+	if c.Key == "" && c.KeyStore != "" {
+		if c.Key, err = c.loadOrGenerateKey(); err != nil {
+			return err
+		}
+	}
+
+	if c.Key == "" {
+		return ErrNoKey
+	}
+
+	key, err := ParseKey(c.Key)
+	if err != nil {
+		return fmt.Errorf("invalid key: %w", err)
+	}
+	c.Key = hex.EncodeToString(key.Seed())
+
+	return nil
+}
+
+// Passphrase returns the key store passphrase from KEY_PASSPHRASE or KEY_PASSPHRASE_FILE
+func (c *Config) Passphrase() (string, error) {
+	if c.KeyPassphrase != "" || c.KeyPassphraseFile == "" {
+		return c.KeyPassphrase, nil
+	}
+
+	return readSecretFile(c.KeyPassphraseFile)
+}
+
+func (c *Config) loadOrGenerateKey() (string, error) {
+	passphrase, err := c.Passphrase()
+	if err != nil {
+		return "", err
+	}
+
+	key, err := LoadKeyStore(c.KeyStore, passphrase)
+	switch {
+	case err == nil:
+		return key, nil
+	case !errors.Is(err, fs.ErrNotExist):
+		return "", err
+	case !c.GenerateKey:
+		return "", nil
+	}
+
+	if key, err = NewKey(); err != nil {
+		return "", fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	return key, SaveKeyStore(c.KeyStore, key, passphrase)
+}
+
+// NewKey generates a random key seed, hex encoded
+func NewKey() (string, error) {
 	_, srvKey, err := ed25519.GenerateKey(nil)
 	if err != nil {
 		return "", err
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// hostSchema is the Home Assistant schema of a HOSTS entry
+const hostSchema = `[{"host":"str","content_dir":"str"}]`
+
+// Host serves the site of its own content directory to the requests for a .ton domain,
+// several domains and subdomains can point at the same ADNL address
+type Host struct {
+	// Host is a host name like site.ton or a pattern like *.site.ton
+	Host       string `json:"host"`
+	ContentDir string `json:"content_dir"`
+}
+
+// validateHosts checks HOSTS, DEFAULT_HOST and CANONICAL_REDIRECT together
+func (c *Config) validateHosts(errs *Errors) {
+	seen := map[string]bool{}
+	for i, h := range c.Hosts {
+		field := fmt.Sprintf("HOSTS[%d]", i)
+
+		name := strings.ToLower(h.Host)
+		if !isHostname(strings.TrimPrefix(name, "*.")) {
+			errs.add(field, "host should be a host name or *.domain, got %q", h.Host)
+		} else if seen[name] {
+			errs.add(field, "host %s is listed twice", h.Host)
+		}
+		seen[name] = true
+
+		if !filepath.IsAbs(h.ContentDir) {
+			errs.add(field, "content_dir should be an absolute path, got %q", h.ContentDir)
+		}
+	}
+
+	if c.DefaultHost != "" {
+		if strings.HasSuffix(strings.ToLower(c.DefaultHost), ".adnl") {
+			errs.add("DEFAULT_HOST", "should be a domain name, not the .adnl address")
+		} else if !isHostname(c.DefaultHost) {
+			errs.add("DEFAULT_HOST", "should be a host name, got %q", c.DefaultHost)
+		} else if len(c.Hosts) > 0 && !seen[strings.ToLower(c.DefaultHost)] {
+			errs.add("DEFAULT_HOST", "should be one of the hosts in HOSTS, got %q", c.DefaultHost)
+		}
+	}
+
+	if c.CanonicalRedirect && c.DefaultHost == "" {
+		errs.add("CANONICAL_REDIRECT", "needs DEFAULT_HOST to redirect to")
+	}
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ParseKey accepts the site key as a hex or base64 encoded 32 byte seed or 64 byte private key,
+// or as a PKCS#8 PEM block. Errors never include the key itself.
+func ParseKey(data string) (ed25519.PrivateKey, error) {
+	data = strings.TrimSpace(data)
+	if data == "" {
+		return nil, errors.New("key is empty")
+	}
+
+	if strings.HasPrefix(data, "-----BEGIN") {
+		return parsePEMKey(data)
+	}
+
+	if len(data)%2 == 0 && isHex(data) {
+		raw, err := hex.DecodeString(data)
+		if err != nil {
+			return nil, errors.New("failed to decode hex key")
+		}
+		return keyFromBytes(raw, "hex")
+	}
+
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if raw, err := enc.DecodeString(data); err == nil {
+			return keyFromBytes(raw, "base64")
+		}
+	}
+
+	return nil, errors.New("key is not hex, base64 or PEM encoded")
+}
+
+func parsePEMKey(data string) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("failed to decode PEM key")
+	}
+	if block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("unsupported PEM block %q, expected PKCS#8 PRIVATE KEY", block.Type)
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.New("failed to parse PKCS#8 key")
+	}
+
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("PEM key is %T, expected ed25519", parsed)
+	}
+
+	return key, nil
+}
+
+func keyFromBytes(raw []byte, encoding string) (ed25519.PrivateKey, error) {
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(raw), nil
+	case ed25519.PrivateKeySize:
+		key := ed25519.NewKeyFromSeed(raw[:ed25519.SeedSize])
+		// the second half of a full private key is its public key, refuse keys where it does not match
+		if !bytes.Equal(key[ed25519.SeedSize:], raw[ed25519.SeedSize:]) {
+			return nil, fmt.Errorf("%s private key has public part not matching its seed", encoding)
+		}
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("%s key should be a %d byte seed or %d byte private key, got %d bytes",
+		encoding, ed25519.SeedSize, ed25519.PrivateKeySize, len(raw))
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,250 @@
+package config
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	keyStoreVersion = 1
+	keyStoreKDF     = "pbkdf2-sha256"
+	keyStoreCipher  = "aes-256-gcm"
+
+	// KeyStoreIterations follows the OWASP recommendation for PBKDF2-HMAC-SHA256
+	KeyStoreIterations = 600_000
+	// maxKeyStoreIterations leaves room for stronger settings, a store asking for more would
+	// stall the start for minutes
+	maxKeyStoreIterations = 10 * KeyStoreIterations
+)
+
+var ErrPassphraseRequired = errors.New("key store is encrypted, set KEY_PASSPHRASE or KEY_PASSPHRASE_FILE")
+
+// encryptedKeyStore is the at rest format of a passphrase protected key store
+type encryptedKeyStore struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Cipher     string `json:"cipher"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// LoadKeyStore reads the hex encoded key seed saved by SaveKeyStore,
+// decrypting it with passphrase when the store is encrypted
+func LoadKeyStore(path, passphrase string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	key := strings.TrimSpace(string(data))
+	if strings.HasPrefix(key, "{") {
+		if passphrase == "" {
+			return "", ErrPassphraseRequired
+		}
+		if key, err = decryptKey([]byte(key), passphrase); err != nil {
+			return "", fmt.Errorf("failed to decrypt key store %s: %w", path, err)
+		}
+	}
+
+	if _, err := hex.DecodeString(key); err != nil {
+		return "", fmt.Errorf("key store %s is corrupted", path)
+	}
+
+	return key, nil
+}
+
+// IsKeyStoreEncrypted reports whether the key store at path is protected with a passphrase
+func IsKeyStoreEncrypted(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	return strings.HasPrefix(strings.TrimSpace(string(data)), "{"), nil
+}
+
+// SaveKeyStore writes the key seed readable only by the owner, encrypted if passphrase is set.
+// It never overwrites an existing key.
+func SaveKeyStore(path, key, passphrase string) error {
+	data, err := encodeKeyStore(key, passphrase)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create key store: %w", err)
+	}
+
+	if _, err = f.Write(data); err != nil {
+		_ = f.Close()
+		_ = os.Remove(path)
+		return fmt.Errorf("failed to write key store: %w", err)
+	}
+
+	return f.Close()
+}
+
+// ErrKeyStoreMismatch is returned by CheckKeyStore when replacing the store would lose its key
+var ErrKeyStoreMismatch = errors.New("key store holds a different key")
+
+// CheckKeyStore makes sure replacing the key store with key keeps the site identity: the store
+// does not exist yet or holds the same key. passphrase decrypts the current store.
+func CheckKeyStore(path, key, passphrase string) error {
+	stored, err := LoadKeyStore(path, passphrase)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	a, err := hex.DecodeString(stored)
+	if err != nil {
+		return err
+	}
+	b, err := hex.DecodeString(key)
+	if err != nil {
+		return fmt.Errorf("invalid key: %w", err)
+	}
+	if !bytes.Equal(a, b) {
+		return ErrKeyStoreMismatch
+	}
+
+	return nil
+}
+
+// ReplaceKeyStore atomically rewrites the key store, used to encrypt it or change its passphrase
+func ReplaceKeyStore(path, key, passphrase string) error {
+	data, err := encodeKeyStore(key, passphrase)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".keystore-*")
+	if err != nil {
+		return fmt.Errorf("failed to create key store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err = tmp.Chmod(0o600); err == nil {
+		if _, err = tmp.Write(data); err == nil {
+			err = tmp.Sync()
+		}
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write key store: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func encodeKeyStore(key, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return []byte(key + "\n"), nil
+	}
+
+	return encryptKey(key, passphrase)
+}
+
+func encryptKey(key, passphrase string) ([]byte, error) {
+	ks := encryptedKeyStore{
+		Version:    keyStoreVersion,
+		KDF:        keyStoreKDF,
+		Iterations: KeyStoreIterations,
+		Salt:       make([]byte, 16),
+		Cipher:     keyStoreCipher,
+	}
+
+	if _, err := rand.Read(ks.Salt); err != nil {
+		return nil, err
+	}
+
+	aead, err := ks.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	ks.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(ks.Nonce); err != nil {
+		return nil, err
+	}
+	ks.Ciphertext = aead.Seal(nil, ks.Nonce, []byte(key), ks.additionalData())
+
+	data, err := json.MarshalIndent(ks, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(data, '\n'), nil
+}
+
+func decryptKey(data []byte, passphrase string) (string, error) {
+	var ks encryptedKeyStore
+	if err := json.Unmarshal(data, &ks); err != nil {
+		return "", fmt.Errorf("invalid format: %w", err)
+	}
+
+	if ks.Version != keyStoreVersion || ks.KDF != keyStoreKDF || ks.Cipher != keyStoreCipher {
+		return "", fmt.Errorf("unsupported format version %d with %s and %s", ks.Version, ks.KDF, ks.Cipher)
+	}
+
+	aead, err := ks.aead(passphrase)
+	if err != nil {
+		return "", err
+	}
+
+	if len(ks.Nonce) != aead.NonceSize() {
+		return "", errors.New("invalid nonce")
+	}
+
+	key, err := aead.Open(nil, ks.Nonce, ks.Ciphertext, ks.additionalData())
+	if err != nil {
+		return "", errors.New("wrong passphrase or damaged key store")
+	}
+
+	return string(key), nil
+}
+
+func (ks *encryptedKeyStore) aead(passphrase string) (cipher.AEAD, error) {
+	if ks.Iterations < 1 || ks.Iterations > maxKeyStoreIterations || len(ks.Salt) == 0 {
+		return nil, errors.New("invalid kdf parameters")
+	}
+
+	dk, err := pbkdf2.Key(sha256.New, passphrase, ks.Salt, ks.Iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(dk)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// additionalData binds the ciphertext to the kdf parameters, so they can't be swapped
+func (ks *encryptedKeyStore) additionalData() []byte {
+	return fmt.Appendf(nil, "ton-site-ha keystore v%d %s %d %x %s", ks.Version, ks.KDF, ks.Iterations, ks.Salt, ks.Cipher)
+}
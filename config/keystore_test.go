@@ -0,0 +1,178 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveKeyGeneratesAndReuses(t *testing.T) {
+	store := filepath.Join(t.TempDir(), "data", "site.key")
+
+	first := &Config{GenerateKey: true, KeyStore: store}
+	if err := first.resolveKey(); err != nil {
+		t.Fatal(err)
+	}
+	if len(first.Key) != 64 {
+		t.Errorf("Expected 32 byte hex seed, but got '%s'", first.Key)
+	}
+
+	st, err := os.Stat(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Mode().Perm() != 0o600 {
+		t.Errorf("Expected 0600 permissions, but got %o", st.Mode().Perm())
+	}
+
+	second := &Config{GenerateKey: true, KeyStore: store}
+	if err := second.resolveKey(); err != nil {
+		t.Fatal(err)
+	}
+	if second.Key != first.Key {
+		t.Error("Expected stored key to be reused")
+	}
+}
+
+func TestResolveKeyWithoutGeneration(t *testing.T) {
+	c := &Config{KeyStore: filepath.Join(t.TempDir(), "site.key")}
+	if err := c.resolveKey(); err == nil {
+		t.Error("Expected error when key is missing and generation is disabled")
+	}
+}
+
+func TestLoadConfigDoesNotGenerate(t *testing.T) {
+	ConfigFileName = filepath.Join(t.TempDir(), "options.json")
+	store := filepath.Join(t.TempDir(), "site.key")
+	t.Setenv("KEY_STORE", store)
+	t.Setenv("GENERATE_KEY", "true")
+
+	if _, err := LoadConfig([]string{"app"}, "test"); !errors.Is(err, ErrNoKey) {
+		t.Errorf("Expected ErrNoKey, but got %v", err)
+	}
+	if _, err := os.Stat(store); !os.IsNotExist(err) {
+		t.Errorf("Expected no key store to be created, but got %v", err)
+	}
+}
+
+func TestSaveKeyStoreDoesNotOverwrite(t *testing.T) {
+	store := filepath.Join(t.TempDir(), "site.key")
+	if err := SaveKeyStore(store, "aa", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveKeyStore(store, "bb", ""); err == nil {
+		t.Error("Expected error when key store already exists")
+	}
+
+	key, err := LoadKeyStore(store, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "aa" {
+		t.Errorf("Expected 'aa', but got '%s'", key)
+	}
+}
+
+func TestEncryptedKeyStore(t *testing.T) {
+	store := filepath.Join(t.TempDir(), "site.key")
+	key := strings.Repeat("ab", 32)
+
+	if err := SaveKeyStore(store, key, "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), key) {
+		t.Error("Expected key store to not contain the key in plaintext")
+	}
+
+	if encrypted, _ := IsKeyStoreEncrypted(store); !encrypted {
+		t.Error("Expected key store to be reported as encrypted")
+	}
+
+	if _, err := LoadKeyStore(store, ""); !errors.Is(err, ErrPassphraseRequired) {
+		t.Errorf("Expected ErrPassphraseRequired, but got %v", err)
+	}
+	if _, err := LoadKeyStore(store, "wrong"); err == nil {
+		t.Error("Expected error for wrong passphrase")
+	}
+
+	loaded, err := LoadKeyStore(store, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded != key {
+		t.Errorf("Expected '%s', but got '%s'", key, loaded)
+	}
+
+	if err := ReplaceKeyStore(store, key, "battery staple"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKeyStore(store, "correct horse"); err == nil {
+		t.Error("Expected old passphrase to stop working")
+	}
+
+	c := &Config{KeyStore: store, KeyPassphrase: "battery staple"}
+	if err := c.resolveKey(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Key != key {
+		t.Errorf("Expected '%s', but got '%s'", key, c.Key)
+	}
+
+	st, err := os.Stat(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Mode().Perm() != 0o600 {
+		t.Errorf("Expected 0600 permissions, but got %o", st.Mode().Perm())
+	}
+}
+
+func TestCheckKeyStore(t *testing.T) {
+	store := filepath.Join(t.TempDir(), "site.key")
+	key, other := strings.Repeat("ab", 32), strings.Repeat("cd", 32)
+
+	if err := CheckKeyStore(store, key, ""); err != nil {
+		t.Errorf("Expected a missing store to be replaceable, but got %v", err)
+	}
+
+	if err := SaveKeyStore(store, key, "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckKeyStore(store, key, "correct horse"); err != nil {
+		t.Errorf("Expected the same key to be accepted, but got %v", err)
+	}
+	if err := CheckKeyStore(store, other, "correct horse"); !errors.Is(err, ErrKeyStoreMismatch) {
+		t.Errorf("Expected ErrKeyStoreMismatch, but got %v", err)
+	}
+	if err := CheckKeyStore(store, key, ""); !errors.Is(err, ErrPassphraseRequired) {
+		t.Errorf("Expected ErrPassphraseRequired, but got %v", err)
+	}
+}
+
+func TestKeyStoreIterationsBound(t *testing.T) {
+	data, err := encodeKeyStore(strings.Repeat("ab", 32), "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ks encryptedKeyStore
+	if err := json.Unmarshal(data, &ks); err != nil {
+		t.Fatal(err)
+	}
+	ks.Iterations = 1 << 40
+	if data, err = json.Marshal(ks); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := decryptKey(data, "correct horse"); err == nil || !strings.Contains(err.Error(), "kdf") {
+		t.Errorf("Expected the iteration count to be rejected, but got %v", err)
+	}
+}
@@ -1,34 +1,88 @@
 package config
 
 import (
+	"encoding/json"
+	"fmt"
+	"log"
 	"os"
 	"strconv"
+	"strings"
 )
 
+// lookupEnvOrString takes the env var, or the content of the file named by key_FILE like
+// Docker secrets, so MNEMONIC or PREVIOUS_KEY can stay out of the environment
 func lookupEnvOrString(key, defaultVal string) string {
 	if val, ok := os.LookupEnv(key); ok {
 		return val
 	}
 
+	if path, ok := os.LookupEnv(key + "_FILE"); ok && path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("failed to read %s_FILE: %s", key, err)
+			return defaultVal
+		}
+
+		return strings.TrimRight(string(data), " \t\r\n")
+	}
+
 	return defaultVal
 }
 
-func lookupEnvOrInt(key string, defaultVal int) int {
+// lookupEnvOrInt keeps the default for a value that is not a number and reports it,
+// a typo must not silently turn an option back to its default
+func lookupEnvOrInt(key string, defaultVal int) (int, error) {
 	if val, ok := os.LookupEnv(key); ok {
-		if x, err := strconv.Atoi(val); err == nil {
-			return x
+		x, err := strconv.Atoi(val)
+		if err != nil {
+			return defaultVal, fmt.Errorf("expected a number, got %q", val)
 		}
+
+		return x, nil
 	}
 
-	return defaultVal
+	return defaultVal, nil
 }
 
-func lookupEnvOrBool(key string, defaultVal bool) bool {
+// lookupEnvOrBool is lookupEnvOrInt for true and false
+func lookupEnvOrBool(key string, defaultVal bool) (bool, error) {
 	if val, ok := os.LookupEnv(key); ok {
-		if x, err := strconv.ParseBool(val); err == nil {
-			return x
+		x, err := strconv.ParseBool(val)
+		if err != nil {
+			return defaultVal, fmt.Errorf("expected true or false, got %q", val)
 		}
+
+		return x, nil
 	}
 
-	return defaultVal
+	return defaultVal, nil
+}
+
+// lookupEnvJSON decodes the env var into value, list options like ROUTES are JSON in env and flags
+func lookupEnvJSON(key string, value any) error {
+	if val, ok := os.LookupEnv(key); ok {
+		if err := json.Unmarshal([]byte(val), value); err != nil {
+			return fmt.Errorf("expected JSON: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// jsonFlag is a flag taking JSON
+type jsonFlag struct {
+	value any
+}
+
+func (f jsonFlag) String() string {
+	if f.value == nil {
+		return ""
+	}
+
+	data, _ := json.Marshal(f.value)
+	return string(data)
+}
+
+func (f jsonFlag) Set(value string) error {
+	return json.Unmarshal([]byte(value), f.value)
 }
@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -21,25 +22,49 @@ func TestLookupEnvOrString(t *testing.T) {
 	}
 }
 
+func TestLookupEnvOrStringFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mnemonic")
+	if err := os.WriteFile(path, []byte("word word word\n\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MNEMONIC_FILE", path)
+
+	if result := lookupEnvOrString("MNEMONIC", "DEFAULT"); result != "word word word" {
+		t.Errorf("Expected the file content, but got '%s'", result)
+	}
+
+	// the env var itself wins over the file
+	t.Setenv("MNEMONIC", "VALUE")
+	if result := lookupEnvOrString("MNEMONIC", "DEFAULT"); result != "VALUE" {
+		t.Errorf("Expected 'VALUE', but got '%s'", result)
+	}
+
+	os.Unsetenv("MNEMONIC")
+	t.Setenv("MNEMONIC_FILE", filepath.Join(t.TempDir(), "missing"))
+	if result := lookupEnvOrString("MNEMONIC", "DEFAULT"); result != "DEFAULT" {
+		t.Errorf("Expected 'DEFAULT' for a missing file, but got '%s'", result)
+	}
+}
+
 func TestLookupEnvOrInt(t *testing.T) {
 	// Test case 1: When the environment variable exists and is a valid integer
 	os.Setenv("KEY", "123")
-	result := lookupEnvOrInt("KEY", 0)
-	if result != 123 {
+	result, err := lookupEnvOrInt("KEY", 0)
+	if err != nil || result != 123 {
 		t.Errorf("Expected 123, but got %d", result)
 	}
 
 	// Test case 2: When the environment variable exists but is not a valid integer
 	os.Setenv("KEY", "abc")
-	result = lookupEnvOrInt("KEY", 0)
-	if result != 0 {
-		t.Errorf("Expected 0, but got %d", result)
+	result, err = lookupEnvOrInt("KEY", 0)
+	if err == nil || result != 0 {
+		t.Errorf("Expected 0 and an error, but got %d, %v", result, err)
 	}
 
 	// Test case 3: When the environment variable does not exist
 	os.Unsetenv("KEY")
-	result = lookupEnvOrInt("KEY", 456)
-	if result != 456 {
+	result, err = lookupEnvOrInt("KEY", 456)
+	if err != nil || result != 456 {
 		t.Errorf("Expected 456, but got %d", result)
 	}
 }
@@ -47,22 +72,22 @@ func TestLookupEnvOrInt(t *testing.T) {
 func TestLookupEnvOrBool(t *testing.T) {
 	// Test case 1: When the environment variable exists and is a valid boolean
 	os.Setenv("KEY", "true")
-	result := lookupEnvOrBool("KEY", false)
-	if result != true {
+	result, err := lookupEnvOrBool("KEY", false)
+	if err != nil || result != true {
 		t.Errorf("Expected true, but got %t", result)
 	}
 
 	// Test case 2: When the environment variable exists but is not a valid boolean
 	os.Setenv("KEY", "abc")
-	result = lookupEnvOrBool("KEY", false)
-	if result != false {
-		t.Errorf("Expected false, but got %t", result)
+	result, err = lookupEnvOrBool("KEY", false)
+	if err == nil || result != false {
+		t.Errorf("Expected false and an error, but got %t, %v", result, err)
 	}
 
 	// Test case 3: When the environment variable does not exist
 	os.Unsetenv("KEY")
-	result = lookupEnvOrBool("KEY", true)
-	if result != true {
+	result, err = lookupEnvOrBool("KEY", true)
+	if err != nil || result != true {
 		t.Errorf("Expected true, but got %t", result)
 	}
 }
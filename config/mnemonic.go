@@ -0,0 +1,91 @@
+package config
+
+import (
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha512"
+	_ "embed"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// MnemonicWords is the length of mnemonics produced by TON wallets
+const MnemonicWords = 24
+
+const (
+	mnemonicIterations = 100000
+	mnemonicSalt       = "TON default seed"
+	mnemonicBasicSalt  = "TON seed version"
+)
+
+// wordlist is the BIP39 english word list, TON mnemonics use the same words
+//
+//go:embed wordlist.txt
+var wordlistData string
+
+var wordlist = strings.Fields(wordlistData)
+
+var wordSet = func() map[string]bool {
+	set := make(map[string]bool, len(wordlist))
+	for _, w := range wordlist {
+		set[w] = true
+	}
+	return set
+}()
+
+// MnemonicToSeed derives the ed25519 seed from a TON mnemonic without password,
+// the same way TON wallets derive their keys
+func MnemonicToSeed(mnemonic string) ([]byte, error) {
+	words := strings.Fields(strings.ToLower(mnemonic))
+	if len(words) != MnemonicWords {
+		return nil, fmt.Errorf("mnemonic should have %d words, got %d", MnemonicWords, len(words))
+	}
+
+	for i, w := range words {
+		if !wordSet[w] {
+			// the position is enough to find a typo, the word itself is a part of the secret
+			return nil, fmt.Errorf("unknown word #%d in mnemonic", i+1)
+		}
+	}
+
+	entropy := mnemonicEntropy(words)
+	if !isBasicMnemonic(entropy) {
+		return nil, errors.New("invalid mnemonic checksum")
+	}
+
+	return pbkdf2.Key(sha512.New, string(entropy), []byte(mnemonicSalt), mnemonicIterations, 32)
+}
+
+// NewMnemonic generates a random mnemonic valid for MnemonicToSeed
+func NewMnemonic() (string, error) {
+	max := big.NewInt(int64(len(wordlist)))
+	words := make([]string, MnemonicWords)
+
+	for {
+		for i := range words {
+			x, err := rand.Int(rand.Reader, max)
+			if err != nil {
+				return "", err
+			}
+			words[i] = wordlist[x.Int64()]
+		}
+
+		if isBasicMnemonic(mnemonicEntropy(words)) {
+			return strings.Join(words, " "), nil
+		}
+	}
+}
+
+func mnemonicEntropy(words []string) []byte {
+	mac := hmac.New(sha512.New, []byte(strings.Join(words, " ")))
+	return mac.Sum(nil)
+}
+
+// isBasicMnemonic checks the marker of mnemonics without password
+func isBasicMnemonic(entropy []byte) bool {
+	p, err := pbkdf2.Key(sha512.New, string(entropy), []byte(mnemonicBasicSalt), mnemonicIterations/256, 1)
+	return err == nil && p[0] == 0
+}
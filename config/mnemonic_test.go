@@ -0,0 +1,103 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// vectors are generated with tonutils-go wallet.SeedToPrivateKey
+var mnemonicVectors = []struct {
+	mnemonic string
+	pubKey   string
+}{
+	{
+		"fluid stick health crane silly dilemma clap speed idle hundred either index lunch scale talk travel song enroll clay pull coin gun door denial",
+		"326d4987e773f852f0c4536a1d7c1edd1fbf20b8d1fff7871eec553cae41a427",
+	},
+	{
+		"melt arctic sentence vehicle danger pony cabin luxury cover wish another jealous pen economy discover series grit ugly beauty embark toward lion kind intact",
+		"18b560a973786640c4ee27b07bdcf3f439f80ae29bde66533fea23d528b0ca35",
+	},
+	{
+		"tragic oven bus tourist coffee useless just skate emotion alert crane prize nasty lonely drastic company thought common plunge door wink aerobic buzz deal",
+		"20c9c7046868d48ff632e3998c94f2456b6d72dd441cbc656c72eabde759599b",
+	},
+}
+
+func TestMnemonicToSeed(t *testing.T) {
+	for _, v := range mnemonicVectors {
+		seed, err := MnemonicToSeed(v.mnemonic)
+		if err != nil {
+			t.Fatalf("%s: %s", v.mnemonic, err)
+		}
+
+		pub := ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey)
+		if hex.EncodeToString(pub) != v.pubKey {
+			t.Errorf("Expected public key %s, but got %x", v.pubKey, pub)
+		}
+	}
+}
+
+func TestMnemonicToSeedNormalizes(t *testing.T) {
+	v := mnemonicVectors[0]
+	messy := "  " + strings.ToUpper(strings.ReplaceAll(v.mnemonic, " ", "\n  ")) + "\n"
+
+	seed, err := MnemonicToSeed(messy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey)) != v.pubKey {
+		t.Error("Expected whitespace and case to be ignored")
+	}
+}
+
+func TestMnemonicToSeedErrors(t *testing.T) {
+	words := strings.Fields(mnemonicVectors[0].mnemonic)
+
+	cases := map[string]string{
+		"too short":    strings.Join(words[:12], " "),
+		"unknown word": strings.Join(append([]string{"tonsite"}, words[1:]...), " "),
+		"bad checksum": strings.Join(append([]string{words[1], words[0]}, words[2:]...), " "),
+	}
+
+	for name, mnemonic := range cases {
+		_, err := MnemonicToSeed(mnemonic)
+		if err == nil {
+			t.Errorf("%s: expected error", name)
+			continue
+		}
+		if strings.Contains(err.Error(), "tonsite") {
+			t.Errorf("%s: error echoes the mnemonic: %s", name, err)
+		}
+	}
+}
+
+func TestNewMnemonic(t *testing.T) {
+	mnemonic, err := NewMnemonic()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := MnemonicToSeed(mnemonic); err != nil {
+		t.Errorf("Expected generated mnemonic to be valid, but got %s", err)
+	}
+}
+
+func TestResolveKeyFromMnemonic(t *testing.T) {
+	v := mnemonicVectors[1]
+
+	c := &Config{Mnemonic: v.mnemonic, GenerateKey: true}
+	if err := c.resolveKey(); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ParseKey(c.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(key.Public().(ed25519.PublicKey)) != v.pubKey {
+		t.Error("Expected key to be derived from the mnemonic")
+	}
+}
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ReloadInterval is how often the options file is checked for changes
+var ReloadInterval = 5 * time.Second
+
+// Changes lists the options which differ between two configs, split into the ones
+// tagged `reload:"live"` which are applied without a restart and the rest
+func Changes(old, new *Config) (live, restart []string) {
+	ov, nv := reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem()
+	t := ov.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() || reflect.DeepEqual(ov.Field(i).Interface(), nv.Field(i).Interface()) {
+			continue
+		}
+
+		name := field.Name
+		if tag, _, _ := strings.Cut(field.Tag.Get("json"), ","); tag != "" && tag != "-" {
+			name = tag
+		}
+
+		if field.Tag.Get("reload") == "live" {
+			live = append(live, name)
+		} else {
+			restart = append(restart, name)
+		}
+	}
+
+	return live, restart
+}
+
+// Watcher reloads the config on SIGHUP or when the options file changes
+type Watcher struct {
+	args    []string
+	version string
+	apply   func(*Config) error
+
+	mx      sync.Mutex
+	current *Config
+	stamp   fileStamp
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewWatcher starts from the running config, apply is called with every
+// new config which passed validation and should return an error to reject it
+func NewWatcher(args []string, version string, current *Config, apply func(*Config) error) *Watcher {
+	return &Watcher{
+		args:    args,
+		version: version,
+		apply:   apply,
+		current: current,
+		stamp:   statConfigFile(),
+	}
+}
+
+// Current returns the config which is running now
+func (w *Watcher) Current() *Config {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+
+	return w.current
+}
+
+// Run waits for SIGHUP or options file changes until ctx is done
+func (w *Watcher) Run(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			log.Println("SIGHUP received, reloading config")
+		case <-ticker.C:
+			w.mx.Lock()
+			changed := statConfigFile() != w.stamp
+			w.mx.Unlock()
+			if !changed {
+				continue
+			}
+			log.Println(ConfigFileName, "changed, reloading config")
+		}
+
+		if err := w.Reload(); err != nil {
+			log.Println("config reload failed, keeping the current config:", err.Error())
+		}
+	}
+}
+
+// Reload reads and validates the config again and applies it, the current
+// config stays in place if anything fails
+func (w *Watcher) Reload() error {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+
+	// remember the file even if it is broken, so it is not reloaded again until the next edit
+	w.stamp = statConfigFile()
+
+	next, err := InitConfig(w.args, w.version)
+	if err != nil {
+		return err
+	}
+
+	live, restart := Changes(w.current, next)
+	if len(live) == 0 && len(restart) == 0 {
+		log.Println("config reloaded, nothing changed")
+		return nil
+	}
+
+	if len(live) > 0 {
+		// the server keeps running with the old key and port, so does everything derived from them
+		merged := liveOnly(w.current, next)
+		if err := w.apply(merged); err != nil {
+			return err
+		}
+		w.current = merged
+
+		log.Println("config reloaded, applied", live)
+	}
+	if len(restart) > 0 {
+		log.Println("restart the add-on to apply", restart)
+	}
+
+	return nil
+}
+
+// liveOnly is a copy of current with the options tagged `reload:"live"` taken from next
+func liveOnly(current, next *Config) *Config {
+	merged := *current
+	mv, nv := reflect.ValueOf(&merged).Elem(), reflect.ValueOf(next).Elem()
+
+	t := mv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("reload") == "live" {
+			mv.Field(i).Set(nv.Field(i))
+		}
+	}
+
+	return &merged
+}
+
+func statConfigFile() fileStamp {
+	info, err := os.Stat(ConfigFileName)
+	if err != nil {
+		return fileStamp{}
+	}
+
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestChanges(t *testing.T) {
+	old := &Config{Key: strings.Repeat("01", 32), ListenPort: "9056"}
+	new := &Config{Key: strings.Repeat("02", 32), ListenPort: "9056", Debug: true}
+
+	live, restart := Changes(old, new)
+	if !slices.Equal(live, []string{"DEBUG"}) {
+		t.Errorf("Expected DEBUG to be applied live, but got %v", live)
+	}
+	if !slices.Equal(restart, []string{"KEY"}) {
+		t.Errorf("Expected KEY to need a restart, but got %v", restart)
+	}
+}
+
+func TestWatcherReload(t *testing.T) {
+	dir := t.TempDir()
+	defer func(name string) { ConfigFileName = name }(ConfigFileName)
+	ConfigFileName = filepath.Join(dir, "options.json")
+
+	key := strings.Repeat("01", 32)
+	write := func(data string) {
+		if err := os.WriteFile(ConfigFileName, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`{"KEY": "` + key + `"}`)
+	current, err := InitConfig([]string{"app"}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var applied *Config
+	var reject error
+	w := NewWatcher([]string{"app"}, "test", current, func(c *Config) error {
+		if reject != nil {
+			return reject
+		}
+		applied = c
+		return nil
+	})
+
+	write(`{"KEY": "` + key + `", "DEBUG": true}`)
+	if err := w.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if applied == nil || !applied.Debug || !w.Current().Debug {
+		t.Fatal("Expected DEBUG to be applied")
+	}
+
+	// a new key needs a restart, the running one stays in the applied config
+	applied = nil
+	other := strings.Repeat("02", 32)
+	write(`{"KEY": "` + other + `", "DEBUG": true, "LISTEN_PORT": "9057", "CONTENT_DIR": "/share/other"}`)
+	if err := w.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if applied == nil || applied.ContentDir != "/share/other" {
+		t.Fatal("Expected CONTENT_DIR to be applied")
+	}
+	if applied.Key != key || applied.ListenPort != "9056" || w.Current().Key != key {
+		t.Errorf("Expected KEY and LISTEN_PORT to be kept until a restart, but got %s %s", applied.Key, applied.ListenPort)
+	}
+
+	applied = nil
+	write(`{"KEY": "` + other + `", "DEBUG": true, "LISTEN_PORT": "9057", "CONTENT_DIR": "/share/other"}`)
+	if err := w.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if applied != nil {
+		t.Error("Expected nothing to be applied when only restart options changed")
+	}
+
+	// broken file, invalid key and a rejected config keep the running one
+	for _, data := range []string{`{"DEBUG": false`, `{"KEY": "nope"}`, `{"KEY": "` + key + `"}`} {
+		reject = errors.New("rejected")
+		write(data)
+		if err := w.Reload(); err == nil {
+			t.Errorf("Expected reload of %s to fail", data)
+		}
+		if !w.Current().Debug {
+			t.Errorf("Expected config to be kept after reload of %s", data)
+		}
+	}
+}
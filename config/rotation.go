@@ -0,0 +1,124 @@
+package config
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+const (
+	// RotationRedirect sends visitors of the previous address to the new one
+	RotationRedirect = "redirect"
+	// RotationMirror serves the site on the previous address as is
+	RotationMirror = "mirror"
+)
+
+// DefaultRotationOverlap is how long the previous key keeps working after `key rotate`
+const DefaultRotationOverlap = 14 * 24 * time.Hour
+
+// Rotation is the overlap period started by `key rotate`, saved next to the previous key store
+// because file times change with backups and restores
+type Rotation struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// RotationFile is where the rotation of the previous key store is recorded
+func RotationFile(previousKeyStore string) string {
+	return previousKeyStore + ".rotation"
+}
+
+// SaveRotation records the overlap period for the previous key store, replacing an old record
+func SaveRotation(previousKeyStore string, r Rotation) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(RotationFile(previousKeyStore), data, 0o600)
+}
+
+// LoadRotation reads the record SaveRotation wrote
+func LoadRotation(previousKeyStore string) (Rotation, error) {
+	var r Rotation
+
+	data, err := os.ReadFile(RotationFile(previousKeyStore))
+	if err != nil {
+		return r, err
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return r, fmt.Errorf("invalid %s: %w", RotationFile(previousKeyStore), err)
+	}
+
+	return r, nil
+}
+
+// resolvePreviousKey loads the key being rotated out from PREVIOUS_KEY or the previous key store
+// and works out when it retires
+func (c *Config) resolvePreviousKey() (err error) {
+	if c.PreviousKey, err = resolveSecret(c.PreviousKey); err != nil {
+		return err
+	}
+
+	var rotation Rotation
+	if c.PreviousKey == "" && c.PreviousKeyStore != "" {
+		passphrase, err := c.Passphrase()
+		if err != nil {
+			return err
+		}
+
+		c.PreviousKey, err = LoadKeyStore(c.PreviousKeyStore, passphrase)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		// a store put there by hand has no record and is served until it is removed
+		if rotation, err = LoadRotation(c.PreviousKeyStore); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	if c.PreviousKey == "" {
+		return nil
+	}
+
+	key, err := ParseKey(c.PreviousKey)
+	if err != nil {
+		return fmt.Errorf("invalid previous key: %w", err)
+	}
+	c.PreviousKey = hex.EncodeToString(key.Seed())
+
+	if c.PreviousKey == c.Key {
+		return errors.New("previous key is the same as the current one")
+	}
+
+	switch {
+	case c.RotationUntil != "":
+		if c.RotationEnd, err = parseRotationUntil(c.RotationUntil); err != nil {
+			return err
+		}
+	case !rotation.End.IsZero():
+		c.RotationEnd = rotation.End
+	}
+
+	return nil
+}
+
+func parseRotationUntil(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid ROTATION_UNTIL %q, expected a date like 2006-01-02 or RFC3339 time", value)
+	}
+
+	return t, nil
+}
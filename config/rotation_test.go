@@ -0,0 +1,73 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolvePreviousKeyFromStore(t *testing.T) {
+	dir := t.TempDir()
+	prev := filepath.Join(dir, "site.key.previous")
+	if err := SaveKeyStore(prev, strings.Repeat("01", 32), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Config{Key: strings.Repeat("02", 32), PreviousKeyStore: prev, RotationMode: RotationRedirect}
+	if err := c.resolvePreviousKey(); err != nil {
+		t.Fatal(err)
+	}
+	if c.PreviousKey != strings.Repeat("01", 32) {
+		t.Errorf("Expected previous key from store, but got '%s'", c.PreviousKey)
+	}
+	if !c.RotationEnd.IsZero() {
+		t.Errorf("Expected no end without a rotation record, but got %s", c.RotationEnd)
+	}
+
+	// the end comes from the record, touching the store does not move it
+	end := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := SaveRotation(prev, Rotation{Start: end.Add(-DefaultRotationOverlap), End: end}); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if err := os.Chtimes(prev, now, now); err != nil {
+		t.Fatal(err)
+	}
+
+	c = &Config{Key: strings.Repeat("02", 32), PreviousKeyStore: prev, RotationMode: RotationRedirect}
+	if err := c.resolvePreviousKey(); err != nil {
+		t.Fatal(err)
+	}
+	if !c.RotationEnd.Equal(end) {
+		t.Errorf("Expected rotation to end at %s, but got %s", end, c.RotationEnd)
+	}
+}
+
+func TestResolvePreviousKeyOptions(t *testing.T) {
+	c := &Config{
+		Key:              strings.Repeat("02", 32),
+		PreviousKey:      strings.Repeat("01", 32),
+		PreviousKeyStore: filepath.Join(t.TempDir(), "missing"),
+		RotationUntil:    "2030-01-02",
+		RotationMode:     RotationMirror,
+	}
+	if err := c.resolvePreviousKey(); err != nil {
+		t.Fatal(err)
+	}
+	if c.RotationEnd.Format(time.DateOnly) != "2030-01-02" {
+		t.Errorf("Expected rotation end 2030-01-02, but got %s", c.RotationEnd)
+	}
+
+	c.PreviousKey, c.RotationMode = c.Key, RotationMirror
+	if err := c.resolvePreviousKey(); err == nil {
+		t.Error("Expected error when previous key equals the current one")
+	}
+
+	c = Defaults("test")
+	c.RotationMode = "proxy"
+	if err := c.Validate(); err == nil {
+		t.Error("Expected error for unknown rotation mode")
+	}
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Route kinds, what a route does with the requests it matches
+const (
+	RouteSite     = "site"
+	RouteStatic   = "static"
+	RouteProxy    = "proxy"
+	RouteRedirect = "redirect"
+	RouteRewrite  = "rewrite"
+	RouteRespond  = "respond"
+)
+
+// routeSchema is the Home Assistant schema of a ROUTES entry
+const routeSchema = `[{"path":"str","host":"str?","type":"list(site|static|proxy|redirect|rewrite|respond)",` +
+	`"target":"str?","match":"str?","status":"int?","body":"str?",` +
+	`"autoindex":"bool?","show_hidden":"bool?","index":"list(serve|list)?","timeout":"int(1,)?"}]`
+
+// Route maps requests for a path prefix, and optionally a host, to a handler kind
+type Route struct {
+	// Path is a prefix, /docs matches /docs and /docs/setup.html but not /docsets
+	Path string `json:"path"`
+	// Host is a host name like site.ton or a pattern like *.site.ton, empty for any host
+	Host string `json:"host,omitempty"`
+	Type string `json:"type"`
+
+	// Target is the content directory of a site, the directory of static files, the URL of
+	// a proxy upstream or of a redirect, or the replacement of a rewrite
+	Target string `json:"target,omitempty"`
+	// Match is the regular expression a rewrite replaces in the path
+	Match string `json:"match,omitempty"`
+	// Status is the code of a redirect or a fixed response
+	Status int    `json:"status,omitempty"`
+	Body   string `json:"body,omitempty"`
+
+	// Autoindex lists the directories of a static route, ShowHidden serves and lists dot files and
+	// Index is serve to show the index.html of a directory or list to list it anyway
+	Autoindex  bool   `json:"autoindex,omitempty"`
+	ShowHidden bool   `json:"show_hidden,omitempty"`
+	Index      string `json:"index,omitempty"`
+
+	// Timeout is how many seconds a proxy upstream has to answer, 30 if not set
+	Timeout int `json:"timeout,omitempty"`
+}
+
+// Routes are matched in order
+type Routes []Route
+
+// validate reports every problem of the route, the field names are the JSON ones
+func (r Route) validate() []string {
+	var problems []string
+	add := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if !strings.HasPrefix(r.Path, "/") {
+		add("path should start with /, got %q", r.Path)
+	}
+	if r.Host != "" && !isHostname(strings.TrimPrefix(r.Host, "*.")) {
+		add("host should be a host name or *.domain, got %q", r.Host)
+	}
+
+	if r.Type != RouteStatic && (r.Autoindex || r.ShowHidden || r.Index != "") {
+		add("autoindex, show_hidden and index are options of static routes")
+	}
+	if r.Type != RouteProxy && r.Timeout != 0 {
+		add("timeout is an option of proxy routes")
+	}
+	if r.Timeout < 0 {
+		add("timeout should be a number of seconds, got %d", r.Timeout)
+	}
+	switch r.Index {
+	case "", "serve", "list":
+	default:
+		add("index should be serve or list, got %q", r.Index)
+	}
+
+	switch r.Type {
+	case RouteSite:
+	case RouteStatic:
+		if r.Target == "" {
+			add("static needs the directory in target")
+		}
+	case RouteProxy:
+		if u, err := url.Parse(r.Target); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("proxy needs an http or https URL in target, got %q", r.Target)
+		}
+	case RouteRedirect:
+		if r.Target == "" {
+			add("redirect needs the URL in target")
+		}
+		switch r.Status {
+		case 0, http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		default:
+			add("redirect status should be 301, 302, 307 or 308, got %d", r.Status)
+		}
+	case RouteRewrite:
+		if r.Match == "" {
+			add("rewrite needs a regular expression in match")
+		} else if _, err := regexp.Compile(r.Match); err != nil {
+			add("invalid match: %s", err)
+		}
+		if !strings.HasPrefix(r.Target, "/") {
+			add("rewrite target should be a path starting with /, got %q", r.Target)
+		}
+	case RouteRespond:
+		if r.Status != 0 && (r.Status < 200 || r.Status > 599) {
+			add("respond status should be from 200 to 599, got %d", r.Status)
+		}
+	default:
+		add("type should be one of site, static, proxy, redirect, rewrite or respond, got %q", r.Type)
+	}
+
+	return problems
+}
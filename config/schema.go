@@ -0,0 +1,93 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// Option is an add-on option as declared in config.json
+type Option struct {
+	Name    string
+	Type    string
+	Default any
+}
+
+// nestedSchemas are the schemas of list and dict options, too long for a struct tag
+var nestedSchemas = map[string]string{
+	"routes": routeSchema,
+	"hosts":  hostSchema,
+}
+
+// Options lists the add-on options in Config order with their Home Assistant schema
+// types from the `schema` tag and the values of Defaults
+func Options() []Option {
+	defaults := reflect.ValueOf(Defaults("")).Elem()
+	t := defaults.Type()
+
+	var options []Option
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		schema := field.Tag.Get("schema")
+		if schema == "" {
+			continue
+		}
+
+		if nested, ok := nestedSchemas[schema]; ok {
+			schema = nested
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		options = append(options, Option{Name: name, Type: schema, Default: defaults.Field(i).Interface()})
+	}
+
+	return options
+}
+
+// AddonSchema renders the options and schema sections of the add-on config.json
+func AddonSchema() ([]byte, error) {
+	var buf bytes.Buffer
+
+	write := func(section string, value func(Option) any) error {
+		buf.WriteString(`"` + section + `":{`)
+		for i, o := range Options() {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+
+			data, err := json.Marshal(map[string]any{o.Name: value(o)})
+			if err != nil {
+				return err
+			}
+			buf.Write(data[1 : len(data)-1])
+		}
+		buf.WriteByte('}')
+
+		return nil
+	}
+
+	buf.WriteByte('{')
+	if err := write("options", func(o Option) any { return o.Default }); err != nil {
+		return nil, err
+	}
+	buf.WriteByte(',')
+	err := write("schema", func(o Option) any {
+		if strings.HasPrefix(o.Type, "[") || strings.HasPrefix(o.Type, "{") {
+			return json.RawMessage(o.Type)
+		}
+		return o.Type
+	})
+	if err != nil {
+		return nil, err
+	}
+	buf.WriteByte('}')
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, buf.Bytes(), "", "  "); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// TestAddonSchema keeps config.json of the add-on in sync with Config,
+// print the expected sections with `app config schema` to update it
+func TestAddonSchema(t *testing.T) {
+	data, err := os.ReadFile("../config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var addon, want struct {
+		Options map[string]any `json:"options"`
+		Schema  map[string]any `json:"schema"`
+	}
+	if err := json.Unmarshal(data, &addon); err != nil {
+		t.Fatal(err)
+	}
+
+	generated, err := AddonSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(generated, &want); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(addon.Schema, want.Schema) {
+		t.Errorf("config.json schema differs from Config, expected:\n%s", generated)
+	}
+	if !reflect.DeepEqual(addon.Options, want.Options) {
+		t.Errorf("config.json options differ from Defaults, expected:\n%s", generated)
+	}
+}
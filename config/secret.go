@@ -0,0 +1,57 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SecretsFileName is Home Assistant's secrets.yaml, available with the homeassistant_config map
+var SecretsFileName = "/homeassistant/secrets.yaml"
+
+const secretPrefix = "!secret "
+
+// resolveSecret replaces a `!secret name` reference with the value from SecretsFileName
+func resolveSecret(value string) (string, error) {
+	if !strings.HasPrefix(value, secretPrefix) {
+		return value, nil
+	}
+
+	name := strings.TrimSpace(strings.TrimPrefix(value, secretPrefix))
+
+	f, err := os.Open(SecretsFileName)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret %q: %w", name, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		k, v, ok := strings.Cut(scanner.Text(), ":")
+		if !ok || strings.TrimSpace(k) != name {
+			continue
+		}
+
+		v = strings.TrimSpace(v)
+		if len(v) >= 2 && (v[0] == '"' && v[len(v)-1] == '"' || v[0] == '\'' && v[len(v)-1] == '\'') {
+			v = v[1 : len(v)-1]
+		}
+		return v, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read secret %q: %w", name, err)
+	}
+
+	return "", fmt.Errorf("secret %q not found in %s", name, SecretsFileName)
+}
+
+// readSecretFile reads a Docker style secret file, surrounding whitespace is ignored
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
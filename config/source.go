@@ -0,0 +1,77 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// Sources of option values, later ones override earlier
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// Setting is the effective value of an option and where it came from
+type Setting struct {
+	Name   string
+	Value  any
+	Source string
+	// Secret options are `password` in the add-on schema and should not be shown
+	Secret bool
+}
+
+// Settings lists all options in Config order as they were parsed, before the keys are resolved
+func (c *Config) Settings() []Setting {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+
+	var settings []Setting
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		source := c.sources[name]
+		if source == "" {
+			source = SourceDefault
+		}
+
+		settings = append(settings, Setting{
+			Name:   name,
+			Value:  v.Field(i).Interface(),
+			Source: source,
+			Secret: strings.HasPrefix(field.Tag.Get("schema"), "password"),
+		})
+	}
+
+	return settings
+}
+
+func (c *Config) setSource(name, source string) {
+	if c.sources == nil {
+		c.sources = map[string]string{}
+	}
+	c.sources[name] = source
+}
+
+// trackSources records which options were overridden by env and flags, flag usage is the option name
+func (c *Config) trackSources(flags *flag.FlagSet) {
+	for name := range optionFields() {
+		if _, ok := os.LookupEnv(name); ok {
+			c.setSource(name, SourceEnv)
+		} else if _, ok := os.LookupEnv(name + "_FILE"); ok {
+			c.setSource(name, SourceEnv)
+		}
+	}
+
+	flags.Visit(func(f *flag.Flag) {
+		c.setSource(f.Usage, SourceFlag)
+	})
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSettingsSources(t *testing.T) {
+	defer func(name string) { ConfigFileName = name }(ConfigFileName)
+	ConfigFileName = filepath.Join(t.TempDir(), "options.json")
+	if err := os.WriteFile(ConfigFileName, []byte(`{"LISTEN_HOST": "localhost", "KEY": "secret"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("LISTEN_PORT", "9057")
+	t.Setenv("KEY", "")
+
+	c, err := Parse([]string{"app", "-debug"}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"LISTEN_HOST": SourceFile,
+		"LISTEN_PORT": SourceEnv,
+		"DEBUG":       SourceFlag,
+		"KEY":         SourceEnv,
+		"STATUS_PAGE": SourceDefault,
+	}
+	for _, s := range c.Settings() {
+		if source, ok := want[s.Name]; ok && s.Source != source {
+			t.Errorf("Expected %s from %s, but got %s", s.Name, source, s.Source)
+		}
+		if s.Name == "KEY" && !s.Secret {
+			t.Error("Expected KEY to be secret")
+		}
+	}
+}
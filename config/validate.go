@@ -0,0 +1,241 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ad/ton-site-ha/internal/httpcache"
+	"github.com/xssnick/tonutils-go/address"
+)
+
+// FieldError is a problem with a single option
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// Errors collects every problem found in the config, so all of them can be fixed in one go
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	lines := make([]string, len(e))
+	for i, fe := range e {
+		lines[i] = fe.Error()
+	}
+
+	return "invalid config:\n  " + strings.Join(lines, "\n  ")
+}
+
+func (e *Errors) add(field, format string, args ...any) {
+	*e = append(*e, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// err returns nil when nothing was found, a nil Errors in an error interface is not nil
+func (e Errors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+
+	return e
+}
+
+// readFile applies the options file over c, unknown options and values of a wrong type are errors
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var options map[string]json.RawMessage
+	if err := json.Unmarshal(data, &options); err != nil {
+		return fmt.Errorf("invalid %s: %w", path, err)
+	}
+
+	fields := optionFields()
+	v := reflect.ValueOf(c).Elem()
+
+	var errs Errors
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		field, ok := fields[name]
+		if !ok {
+			errs.add(name, "unknown option in %s", path)
+			continue
+		}
+
+		if err := json.Unmarshal(options[name], v.FieldByIndex(field.Index).Addr().Interface()); err != nil {
+			// keys and mnemonics stay out of the log, even when they are of the wrong type
+			if strings.HasPrefix(field.Tag.Get("schema"), "password") {
+				errs.add(name, "expected a %s value in %s", field.Type.Kind(), path)
+			} else {
+				errs.add(name, "expected a %s value in %s, got %s", field.Type.Kind(), path, options[name])
+			}
+			continue
+		}
+		c.setSource(name, SourceFile)
+	}
+
+	return errs.err()
+}
+
+// optionFields maps option names to the Config fields they are read into
+func optionFields() map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name != "" && name != "-" {
+			fields[name] = field
+		}
+	}
+
+	return fields
+}
+
+// Validate checks the values of all options, `!secret` references are checked once resolved
+func (c *Config) Validate() error {
+	var errs Errors
+
+	if port, err := strconv.Atoi(c.ListenPort); err != nil || port < 1 || port > 65535 {
+		errs.add("LISTEN_PORT", "should be a port number from 1 to 65535, got %q", c.ListenPort)
+	}
+
+	if c.StatusPage || c.Metrics {
+		if _, port, err := net.SplitHostPort(c.StatusListen); err != nil {
+			errs.add("STATUS_LISTEN", "should be a host:port address like %s, got %q", DefaultStatusListen, c.StatusListen)
+		} else if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			errs.add("STATUS_LISTEN", "should end with a port number from 1 to 65535, got %q", c.StatusListen)
+		}
+	}
+
+	if c.ListenHost != "" && net.ParseIP(c.ListenHost) == nil && !isHostname(c.ListenHost) {
+		errs.add("LISTEN_HOST", "should be an IP address or a host name, got %q", c.ListenHost)
+	}
+
+	if c.Key != "" && !strings.HasPrefix(c.Key, secretPrefix) {
+		if _, err := ParseKey(c.Key); err != nil {
+			errs.add("KEY", "%s", err)
+		}
+	}
+
+	if c.Mnemonic != "" && !strings.HasPrefix(c.Mnemonic, secretPrefix) {
+		if _, err := MnemonicToSeed(c.Mnemonic); err != nil {
+			errs.add("MNEMONIC", "%s", err)
+		}
+	}
+
+	if c.PreviousKey != "" && !strings.HasPrefix(c.PreviousKey, secretPrefix) {
+		if _, err := ParseKey(c.PreviousKey); err != nil {
+			errs.add("PREVIOUS_KEY", "%s", err)
+		}
+	}
+
+	if _, err := httpcache.ParseRules(c.CacheControl); err != nil {
+		errs.add("CACHE_CONTROL", "%s", err)
+	}
+
+	for i, route := range c.Routes {
+		for _, problem := range route.validate() {
+			errs.add(fmt.Sprintf("ROUTES[%d]", i), "%s", problem)
+		}
+	}
+
+	c.validateHosts(&errs)
+
+	if c.ResponseCacheMB < 0 {
+		errs.add("RESPONSE_CACHE_MB", "should be 0 or more, got %d", c.ResponseCacheMB)
+	}
+
+	for _, h := range []struct{ field, value string }{
+		{"CONTENT_SECURITY_POLICY", c.ContentSecurityPolicy},
+		{"REFERRER_POLICY", c.ReferrerPolicy},
+		{"PERMISSIONS_POLICY", c.PermissionsPolicy},
+	} {
+		if strings.ContainsAny(h.value, "\r\n\x00") {
+			errs.add(h.field, "should be a single line header value")
+		}
+	}
+
+	if c.RotationMode != RotationRedirect && c.RotationMode != RotationMirror {
+		errs.add("ROTATION_MODE", "should be %s or %s, got %q", RotationRedirect, RotationMirror, c.RotationMode)
+	}
+
+	if c.RotationUntil != "" {
+		if _, err := parseRotationUntil(c.RotationUntil); err != nil {
+			errs.add("ROTATION_UNTIL", "should be a date like 2006-01-02 or RFC3339 time, got %q", c.RotationUntil)
+		}
+	}
+
+	if c.DomainNFTAddress != "" {
+		if _, err := address.ParseAddr(c.DomainNFTAddress); err != nil {
+			errs.add("DOMAIN_NFT_ADDRESS", "should be a TON address, got %q", c.DomainNFTAddress)
+		}
+	}
+
+	return errs.err()
+}
+
+// Check validates the config and resolves the keys the same way InitConfig does,
+// but never generates a key
+func Check(args []string, version string) error {
+	c, err := Parse(args, version)
+	if err != nil {
+		return err
+	}
+
+	var errs Errors
+
+	// a missing key store is fine when the key is generated on the first start
+	generate := c.GenerateKey && c.KeyStore != ""
+	c.GenerateKey = false
+	if err := c.resolveKey(); err != nil && !(generate && errors.Is(err, ErrNoKey)) {
+		errs.add("KEY", "%s", err)
+	}
+
+	if err := c.resolvePreviousKey(); err != nil {
+		errs.add("PREVIOUS_KEY", "%s", err)
+	}
+
+	return errs.err()
+}
+
+// isHostname checks the RFC 1123 host name syntax
+func isHostname(host string) bool {
+	if len(host) > 253 {
+		return false
+	}
+
+	for _, label := range strings.Split(strings.TrimSuffix(host, "."), ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+
+		for _, r := range label {
+			if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '-' {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	c := Defaults("test")
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Expected defaults to be valid, but got %s", err)
+	}
+
+	c.ListenPort = "70000"
+	c.ListenHost = "bad host"
+	c.Key = "not a key"
+	c.RotationUntil = "tomorrow"
+	c.CacheControl = "static=no-cache"
+	c.ContentSecurityPolicy = "default-src 'self'\r\nSet-Cookie: a=b"
+
+	var errs Errors
+	if !errors.As(c.Validate(), &errs) {
+		t.Fatal("Expected Errors")
+	}
+
+	fields := map[string]bool{}
+	for _, fe := range errs {
+		fields[fe.Field] = true
+	}
+	for _, field := range []string{"LISTEN_PORT", "LISTEN_HOST", "KEY", "ROTATION_UNTIL", "CACHE_CONTROL", "CONTENT_SECURITY_POLICY"} {
+		if !fields[field] {
+			t.Errorf("Expected error for %s, but got %s", field, errs)
+		}
+	}
+	if strings.Contains(errs.Error(), "not a key") {
+		t.Error("Expected key to be left out of the error")
+	}
+
+	c = Defaults("test")
+	for _, host := range []string{"localhost", "0.0.0.0", "::1", "ha.local"} {
+		c.ListenHost = host
+		if err := c.Validate(); err != nil {
+			t.Errorf("Expected %s to be valid, but got %s", host, err)
+		}
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "options.json")
+	if err := os.WriteFile(path, []byte(`{"LISTEN_PORT": 9056, "DEBUG": true, "LISTEN_HOTS": ""}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := Defaults("test")
+
+	var errs Errors
+	if !errors.As(c.readFile(path), &errs) {
+		t.Fatal("Expected Errors")
+	}
+	if len(errs) != 2 || errs[0].Field != "LISTEN_HOTS" || errs[1].Field != "LISTEN_PORT" {
+		t.Errorf("Expected errors for LISTEN_HOTS and LISTEN_PORT, but got %s", errs)
+	}
+	if !c.Debug {
+		t.Error("Expected valid options to be read")
+	}
+}
+
+func TestParseReportsAll(t *testing.T) {
+	defer func(name string) { ConfigFileName = name }(ConfigFileName)
+	ConfigFileName = filepath.Join(t.TempDir(), "options.json")
+	options := `{"KEY": 12345678, "MNEMONIC": ["word", "word"], "LISTEN_HOTS": "", "ROTATION_MODE": "copy"}`
+	if err := os.WriteFile(ConfigFileName, []byte(options), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("STATUS_PAGE", "yes please")
+	t.Setenv("RESPONSE_CACHE_MB", "64MB")
+
+	_, err := Parse([]string{"test"}, "test")
+
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected Errors, but got %v", err)
+	}
+	fields := map[string]bool{}
+	for _, fe := range errs {
+		fields[fe.Field] = true
+	}
+	for _, field := range []string{"KEY", "MNEMONIC", "LISTEN_HOTS", "ROTATION_MODE", "STATUS_PAGE", "RESPONSE_CACHE_MB"} {
+		if !fields[field] {
+			t.Errorf("Expected error for %s, but got %s", field, errs)
+		}
+	}
+	if strings.Contains(errs.Error(), "12345678") || strings.Contains(errs.Error(), "word") {
+		t.Errorf("Expected secrets to be left out of the errors, but got %s", errs)
+	}
+}
+
+func TestValidateRoutes(t *testing.T) {
+	c := Defaults("test")
+	c.Routes = Routes{
+		{Path: "/", Type: RouteSite},
+		{Path: "/app/", Type: RouteProxy, Target: "http://127.0.0.1:8080", Timeout: 60},
+		{Path: "/old", Type: RouteRedirect, Target: "/new", Status: 308},
+		{Path: "/p", Type: RouteRewrite, Match: `^/p/(\d+)$`, Target: "/blog/$1/"},
+		{Path: "/ok", Host: "*.site.ton", Type: RouteRespond, Body: "ok"},
+		{Path: "/files/", Type: RouteStatic, Target: "/share", Autoindex: true, Index: "list"},
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Expected routes to be valid, but got %s", err)
+	}
+
+	c.Routes = Routes{
+		{Path: "api", Type: RouteProxy, Target: "ftp://host"},
+		{Path: "/old", Type: RouteRedirect, Status: 200},
+		{Path: "/p", Type: RouteRewrite, Match: `(`},
+		{Path: "/x", Type: "cgi"},
+		{Path: "/files/", Type: RouteStatic, Target: "/share", Autoindex: true, Index: "all"},
+		{Path: "/api/", Type: RouteProxy, Target: "http://host", Autoindex: true},
+		{Path: "/health", Type: RouteRespond, Timeout: 5},
+	}
+
+	var errs Errors
+	if !errors.As(c.Validate(), &errs) {
+		t.Fatal("Expected Errors")
+	}
+	count := map[string]int{}
+	for _, fe := range errs {
+		count[fe.Field]++
+	}
+	want := map[string]int{"ROUTES[0]": 2, "ROUTES[1]": 2, "ROUTES[2]": 2, "ROUTES[3]": 1, "ROUTES[4]": 1, "ROUTES[5]": 1, "ROUTES[6]": 1}
+	for field, n := range want {
+		if count[field] != n {
+			t.Errorf("Expected %d errors for %s, but got %s", n, field, errs)
+		}
+	}
+}
+
+func TestRoutesEnv(t *testing.T) {
+	t.Setenv("ROUTES", `[{"path": "/health", "type": "respond", "body": "ok"}]`)
+
+	c, err := Parse([]string{"app"}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Routes) != 1 || c.Routes[0].Body != "ok" {
+		t.Errorf("Expected the route from env, but got %+v", c.Routes)
+	}
+
+	t.Setenv("ROUTES", `not json`)
+	if _, err := Parse([]string{"app"}, "test"); err == nil {
+		t.Error("Expected error for invalid ROUTES")
+	}
+}
+
+func TestValidateHosts(t *testing.T) {
+	c := Defaults("test")
+	c.Hosts = []Host{{Host: "site.ton", ContentDir: "/share/site"}, {Host: "*.site.ton", ContentDir: "/share/sub"}}
+	c.DefaultHost = "site.ton"
+	c.CanonicalRedirect = true
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Expected hosts to be valid, but got %s", err)
+	}
+
+	c.Hosts = append(c.Hosts, Host{Host: "Site.ton", ContentDir: "relative"})
+	c.DefaultHost = "other.ton"
+
+	var errs Errors
+	if !errors.As(c.Validate(), &errs) {
+		t.Fatal("Expected Errors")
+	}
+	count := map[string]int{}
+	for _, fe := range errs {
+		count[fe.Field]++
+	}
+	if count["HOSTS[2]"] != 2 || count["DEFAULT_HOST"] != 1 {
+		t.Errorf("Expected duplicate host, relative dir and unknown default host errors, but got %s", errs)
+	}
+
+	c = Defaults("test")
+	c.CanonicalRedirect = true
+	if err := c.Validate(); err == nil || !strings.Contains(err.Error(), "CANONICAL_REDIRECT") {
+		t.Errorf("Expected CANONICAL_REDIRECT to need DEFAULT_HOST, but got %v", err)
+	}
+}
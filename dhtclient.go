@@ -0,0 +1,77 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"time"
+
+	"github.com/ad/ton-site-ha/config"
+	"github.com/ad/ton-site-ha/internal/dhtcache"
+	rldphttp "github.com/ad/ton-site-ha/internal/rldphttp"
+
+	"github.com/xssnick/tonutils-go/adnl"
+	"github.com/xssnick/tonutils-go/adnl/dht"
+	"github.com/xssnick/tonutils-go/liteclient"
+)
+
+// newDHTClient creates the DHT client either on the server's own gateway or on a separate
+// client gateway with a persisted key, restoring the routing table saved on previous runs
+func newDHTClient(conf *config.Config, s *rldphttp.Server, netCfg *liteclient.GlobalConfig) (*dht.Client, error) {
+	var gateway *dhtcache.Gateway
+	if conf.DHTSharedGateway {
+		gateway = dhtcache.Wrap(s.Gateway(), true)
+	} else {
+		key, err := dhtKey(conf.DHTKeyStore)
+		if err != nil {
+			return nil, err
+		}
+
+		g := adnl.NewGateway(key)
+		if err := g.StartClient(); err != nil {
+			return nil, fmt.Errorf("failed to start dht gateway: %w", err)
+		}
+		gateway = dhtcache.Wrap(g, false)
+	}
+
+	nodes := dhtcache.NodesFromConfig(netCfg)
+	if conf.DHTNodesCache != "" {
+		cached, err := dhtcache.Load(conf.DHTNodesCache)
+		if err != nil {
+			log.Println("failed to load dht nodes cache:", err.Error())
+		}
+		nodes = append(nodes, cached...)
+
+		go func() {
+			for {
+				time.Sleep(10 * time.Minute)
+				if err := gateway.Save(conf.DHTNodesCache); err != nil {
+					log.Println("failed to save dht nodes cache:", err.Error())
+				}
+			}
+		}()
+	}
+
+	return dht.NewClient(gateway, nodes)
+}
+
+// dhtKey loads the DHT client key from path, creating it on first run.
+// Without a path a new key is used on every start.
+func dhtKey(path string) (ed25519.PrivateKey, error) {
+	seed, err := config.LoadKeyStore(path, "")
+	if path == "" || errors.Is(err, fs.ErrNotExist) {
+		if seed, err = config.NewKey(); err != nil {
+			return nil, fmt.Errorf("failed to generate ed25519 key for dht: %w", err)
+		}
+		if path != "" {
+			err = config.SaveKeyStore(path, seed, "")
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load dht key: %w", err)
+	}
+
+	return config.ParseKey(seed)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"github.com/ad/ton-site-ha/config"
+	rldphttp "github.com/ad/ton-site-ha/internal/rldphttp"
+	"github.com/ad/ton-site-ha/internal/tondns"
+
+	"github.com/skip2/go-qrcode"
+	"github.com/xssnick/tonutils-go/address"
+)
+
+// dnsRecord describes the change of the domain's site record to our ADNL address
+type dnsRecord struct {
+	Address   string
+	RecordKey string
+	Payload   string
+	Link      string
+}
+
+func newDNSRecord(adnlID []byte, domainNFT string) (*dnsRecord, error) {
+	addr, err := rldphttp.SerializeADNLAddress(adnlID)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := tondns.ChangeSiteRecord(adnlID, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	rec := &dnsRecord{
+		Address:   addr + ".adnl",
+		RecordKey: hex.EncodeToString(tondns.CategorySite[:]),
+		Payload:   base64.StdEncoding.EncodeToString(body.ToBOC()),
+	}
+
+	if domainNFT != "" {
+		nft, err := address.ParseAddr(domainNFT)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse domain nft address: %w", err)
+		}
+		rec.Link = tondns.TransferLink(nft, tondns.DefaultAmount, body)
+	}
+
+	return rec, nil
+}
+
+// QR returns the transfer link encoded as a PNG image
+func (r *dnsRecord) QR() ([]byte, error) {
+	return qrcode.Encode(r.Link, qrcode.Medium, 256)
+}
+
+func dnsRecordCommand(args []string) error {
+	conf, err := config.LoadConfig(args, version)
+	if err != nil {
+		return err
+	}
+
+	key, err := config.ParseKey(conf.Key)
+	if err != nil {
+		return fmt.Errorf("failed to get key: %w", err)
+	}
+
+	id, err := adnlID(key)
+	if err != nil {
+		return err
+	}
+
+	rec, err := newDNSRecord(id, conf.DomainNFTAddress)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("ADNL address:", rec.Address)
+	fmt.Println("Record key (site):", rec.RecordKey)
+	fmt.Println("Payload (base64 BOC):", rec.Payload)
+
+	if rec.Link == "" {
+		fmt.Fprintln(os.Stderr, "set DOMAIN_NFT_ADDRESS to get a transfer link for your wallet")
+		return nil
+	}
+
+	fmt.Println("Transfer link:", rec.Link)
+
+	qr, err := qrcode.New(rec.Link, qrcode.Low)
+	if err != nil {
+		return err
+	}
+	fmt.Println(qr.ToSmallString(false))
+
+	return nil
+}
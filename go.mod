@@ -5,7 +5,9 @@ go 1.24.0
 require (
 	github.com/joho/godotenv v1.5.1
 	github.com/sigurn/crc16 v0.0.0-20240131213347-83fcde1e29d1
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	github.com/xssnick/tonutils-go v1.16.0
+	github.com/yuin/goldmark v1.7.17
 )
 
 require (
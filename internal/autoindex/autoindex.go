@@ -0,0 +1,242 @@
+// Package autoindex lists the directories of a static route through the site layout
+package autoindex
+
+import (
+	"cmp"
+	"errors"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ad/ton-site-ha/internal/content"
+	"github.com/ad/ton-site-ha/internal/render"
+)
+
+// Template renders listings, it is in TemplatesDir and not served as a page
+const Template = "autoindex/list.html"
+
+// Index modes, what a directory with an index.html shows
+const (
+	IndexServe = "serve"
+	IndexList  = "list"
+)
+
+// Options of a listed route
+type Options struct {
+	// ShowHidden lists and serves files and directories starting with a dot
+	ShowHidden bool
+	// Index is IndexServe to show the index.html of a directory instead of listing it
+	Index string
+}
+
+// Entry is a file or directory in a listing
+type Entry struct {
+	Name    string
+	URL     string
+	Dir     bool
+	Size    int64
+	ModTime time.Time
+}
+
+// Crumb is a step of the breadcrumb navigation
+type Crumb struct {
+	Name string
+	URL  string
+}
+
+// Listing is the Content of the listing template
+type Listing struct {
+	// Path is the URL path of the directory, unescaped for display
+	Path    string
+	Crumbs  []Crumb
+	Entries []Entry
+
+	// Sort is name, size or modified, Desc reverses it
+	Sort string
+	Desc bool
+}
+
+// SortLink is the query sorting the listing by key, reversing the order if it is sorted by it already
+func (l Listing) SortLink(key string) string {
+	order := "asc"
+	if key == l.Sort && !l.Desc {
+		order = "desc"
+	}
+
+	return "?sort=" + key + "&order=" + order
+}
+
+// Handler serves the files of fsys under prefix and lists its directories with set
+type Handler struct {
+	prefix string
+	fsys   fs.FS
+	set    *render.Set
+	opts   Options
+	files  http.Handler
+}
+
+// New lists the directories of fsys under the route prefix
+func New(prefix string, fsys fs.FS, set *render.Set, opts Options) *Handler {
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	return &Handler{
+		prefix: prefix,
+		fsys:   fsys,
+		set:    set,
+		opts:   opts,
+		files:  http.StripPrefix(prefix, http.FileServer(http.FS(fsys))),
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rel, ok := strings.CutPrefix(r.URL.Path, h.prefix)
+	if !ok {
+		h.set.Error(w, r, http.StatusNotFound)
+		return
+	}
+
+	// the names are checked as a whole, the file system refuses anything leaving the root
+	name := strings.Trim(rel, "/")
+	if name == "" {
+		name = "."
+	}
+	if !fs.ValidPath(name) || (!h.opts.ShowHidden && content.Hidden(name)) {
+		h.set.Error(w, r, http.StatusNotFound)
+		return
+	}
+
+	st, err := fs.Stat(h.fsys, name)
+	if err != nil {
+		h.set.Error(w, r, http.StatusNotFound)
+		return
+	}
+	if !st.IsDir() || !strings.HasSuffix(r.URL.Path, "/") {
+		// files, and directories without the slash the file server redirects to
+		h.files.ServeHTTP(w, r)
+		return
+	}
+
+	if h.opts.Index != IndexList {
+		if _, err := fs.Stat(h.fsys, path.Join(name, "index.html")); err == nil {
+			h.files.ServeHTTP(w, r)
+			return
+		}
+	}
+
+	listing, err := h.list(name, r.URL.Query())
+	if err != nil {
+		log.Printf("failed to list %s: %s", name, err)
+		h.set.Error(w, r, http.StatusInternalServerError)
+		return
+	}
+
+	page, err := h.set.Page(Template)
+	if err == nil && page == nil {
+		err = errors.New(Template + " template not found")
+	}
+	if err != nil {
+		log.Print(err.Error())
+		h.set.Error(w, r, http.StatusInternalServerError)
+		return
+	}
+
+	data := h.set.Data(r, page)
+	data.Title, data.Content = "Index of "+listing.Path, listing
+	h.set.Write(w, r, http.StatusOK, page, data)
+}
+
+func (h *Handler) list(name string, query url.Values) (*Listing, error) {
+	dirs, err := fs.ReadDir(h.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	base, display := h.prefix+"/", h.prefix+"/"
+	if name != "." {
+		base += escape(name) + "/"
+		display += name + "/"
+	}
+
+	l := &Listing{Path: display, Sort: query.Get("sort"), Desc: query.Get("order") == "desc"}
+	for _, d := range dirs {
+		if !h.opts.ShowHidden && content.Hidden(d.Name()) {
+			continue
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			continue
+		}
+
+		e := Entry{Name: d.Name(), URL: base + url.PathEscape(d.Name()), Dir: d.IsDir(), ModTime: info.ModTime()}
+		if e.Dir {
+			e.URL += "/"
+		} else {
+			e.Size = info.Size()
+		}
+		l.Entries = append(l.Entries, e)
+	}
+	l.sort()
+
+	l.Crumbs = []Crumb{{Name: path.Base(h.prefix + "/"), URL: h.prefix + "/"}}
+	if h.prefix == "" {
+		l.Crumbs[0].Name = "/"
+	}
+	if name != "." {
+		url := h.prefix + "/"
+		for _, part := range strings.Split(name, "/") {
+			url += escape(part) + "/"
+			l.Crumbs = append(l.Crumbs, Crumb{Name: part, URL: url})
+		}
+	}
+
+	return l, nil
+}
+
+// sort puts directories first, then orders by the column, by name for ties
+func (l *Listing) sort() {
+	switch l.Sort {
+	case "size", "modified":
+	default:
+		l.Sort = "name"
+	}
+
+	slices.SortStableFunc(l.Entries, func(a, b Entry) int {
+		if a.Dir != b.Dir {
+			if a.Dir {
+				return -1
+			}
+			return 1
+		}
+
+		c := 0
+		switch l.Sort {
+		case "size":
+			c = cmp.Compare(a.Size, b.Size)
+		case "modified":
+			c = a.ModTime.Compare(b.ModTime)
+		}
+		if c == 0 {
+			c = strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+		}
+		if l.Desc {
+			c = -c
+		}
+
+		return c
+	})
+}
+
+func escape(name string) string {
+	parts := strings.Split(name, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+
+	return strings.Join(parts, "/")
+}
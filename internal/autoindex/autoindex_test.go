@@ -0,0 +1,148 @@
+package autoindex
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/ad/ton-site-ha/internal/render"
+)
+
+func testSet(t *testing.T) *render.Set {
+	t.Helper()
+
+	files := fstest.MapFS{
+		"templates/layout.html":     {Data: []byte(`{{define "layout"}}<title>{{template "title" .}}</title>{{template "body" .}}{{end}}`)},
+		"templates/errors/404.html": {Data: []byte(`{{define "title"}}{{.Content.Code}}{{end}}{{define "body"}}{{.Content.Text}}{{end}}`)},
+		"templates/" + Template: {Data: []byte(`{{define "title"}}{{.Title}}{{end}}{{define "body"}}{{with .Content}}` +
+			`{{range .Crumbs}}({{.Name}}:{{.URL}}){{end}}{{range .Entries}}[{{.Name}} {{.URL}} {{if not .Dir}}{{size .Size}}{{end}}]{{end}}{{end}}{{end}}`)},
+	}
+
+	set, err := render.NewSet(files, render.Site{}, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return set
+}
+
+func testFiles() fstest.MapFS {
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	return fstest.MapFS{
+		"b.txt":                 {Data: []byte("bb"), ModTime: day},
+		"a b.txt":               {Data: make([]byte, 2048), ModTime: day.Add(time.Hour)},
+		"C.txt":                 {Data: []byte("c"), ModTime: day.Add(-time.Hour)},
+		".secret":               {Data: []byte("key")},
+		"docs/guide.txt":        {Data: []byte("guide")},
+		"docs/sub/deep.txt":     {Data: []byte("deep")},
+		"site/index.html":       {Data: []byte("<p>home</p>")},
+		".git/config":           {Data: []byte("[core]")},
+		"empty/.keep":           {Data: []byte{}},
+		"site/other.html":       {Data: []byte("other")},
+		"docs/sub/.hidden.html": {Data: []byte("hidden")},
+	}
+}
+
+func get(h http.Handler, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "http://site.adnl/", nil)
+	r.URL.Path = path
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestListing(t *testing.T) {
+	h := New("/files/", testFiles(), testSet(t), Options{})
+
+	cases := map[string]struct {
+		code int
+		body string
+	}{
+		"/files/": {200, "<title>Index of /files/</title>(files:/files/)" +
+			"[docs /files/docs/ ][empty /files/empty/ ][site /files/site/ ]" +
+			"[a b.txt /files/a%20b.txt 2.0 KiB][b.txt /files/b.txt 2 B][C.txt /files/C.txt 1 B]"},
+		"/files/docs/sub/":             {200, "(files:/files/)(docs:/files/docs/)(sub:/files/docs/sub/)[deep.txt /files/docs/sub/deep.txt 4 B]"},
+		"/files/docs/guide.txt":        {200, "guide"},
+		"/files/site/":                 {200, "<p>home</p>"},
+		"/files/empty/":                {200, "<title>Index of /files/empty/</title>"},
+		"/files/missing/":              {404, ""},
+		"/files/.secret":               {404, ""},
+		"/files/.git/":                 {404, ""},
+		"/files/.git/config":           {404, ""},
+		"/files/docs/sub/.hidden.html": {404, ""},
+		"/files/docs/../../etc/passwd": {404, ""},
+		"/files/docs/./guide.txt":      {404, ""},
+		"/files//etc/passwd":           {404, ""},
+		"/files/docs\\..\\.secret":     {404, ""},
+	}
+
+	for path, c := range cases {
+		w := get(h, path)
+		if w.Code != c.code {
+			t.Errorf("%s: expected %d, but got %d", path, c.code, w.Code)
+		}
+		if c.body != "" && !strings.Contains(w.Body.String(), c.body) {
+			t.Errorf("%s: expected %q in %q", path, c.body, w.Body.String())
+		}
+	}
+
+	if w := get(h, "/files/docs"); w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "docs/" {
+		t.Errorf("Expected a redirect to docs/, but got %d %q", w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestListingSort(t *testing.T) {
+	h := New("/files", testFiles(), testSet(t), Options{})
+
+	cases := map[string]string{
+		"?sort=name&order=desc":     "[C.txt /files/C.txt 1 B][b.txt /files/b.txt 2 B][a b.txt",
+		"?sort=size":                "[C.txt /files/C.txt 1 B][b.txt /files/b.txt 2 B][a b.txt",
+		"?sort=modified&order=desc": "[a b.txt /files/a%20b.txt 2.0 KiB][b.txt /files/b.txt 2 B][C.txt",
+		"?sort=bogus":               "[a b.txt /files/a%20b.txt 2.0 KiB][b.txt /files/b.txt 2 B][C.txt",
+	}
+
+	for query, want := range cases {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://site.adnl/files/"+query, nil))
+		body := w.Body.String()
+		if !strings.Contains(body, want) {
+			t.Errorf("%s: expected %q in %q", query, want, body)
+		}
+		if !strings.Contains(body, "[docs /files/docs/ ]") || strings.Index(body, "[docs") > strings.Index(body, "[C.txt") {
+			t.Errorf("%s: expected directories first in %q", query, body)
+		}
+	}
+
+	l := Listing{Sort: "size"}
+	if got := l.SortLink("size"); got != "?sort=size&order=desc" {
+		t.Errorf("Expected the reversed order, but got %q", got)
+	}
+	if got := l.SortLink("name"); got != "?sort=name&order=asc" {
+		t.Errorf("Expected the ascending order, but got %q", got)
+	}
+}
+
+func TestListingOptions(t *testing.T) {
+	h := New("/", testFiles(), testSet(t), Options{ShowHidden: true, Index: IndexList})
+
+	w := get(h, "/")
+	if !strings.Contains(w.Body.String(), "[.git /.git/ ]") || !strings.Contains(w.Body.String(), "[.secret /.secret 3 B]") {
+		t.Errorf("Expected hidden files listed, but got %q", w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "(/:/)") {
+		t.Errorf("Expected the root crumb, but got %q", w.Body.String())
+	}
+
+	if w := get(h, "/.secret"); w.Code != http.StatusOK || w.Body.String() != "key" {
+		t.Errorf("Expected the hidden file served, but got %d %q", w.Code, w.Body.String())
+	}
+
+	w = get(h, "/site/")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "[index.html /site/index.html") {
+		t.Errorf("Expected the directory with index.html listed, but got %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,127 @@
+// Package blog serves Markdown posts from the content directory rendered into the site layout
+package blog
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Prefix is where the blog is served
+const Prefix = "/blog/"
+
+// PostsDir holds the Markdown posts in the content directory
+const PostsDir = "posts"
+
+// PageSize is how many posts an index page shows
+const PageSize = 10
+
+// Blog is the set of published posts, newest first
+type Blog struct {
+	Posts []*Post
+	tags  map[string][]*Post
+}
+
+// Load reads all posts from PostsDir, drafts and posts which fail to parse are skipped and
+// a missing directory is an empty blog
+func Load(files fs.FS) (*Blog, error) {
+	b := &Blog{tags: map[string][]*Post{}}
+
+	entries, err := fs.ReadDir(files, PostsDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return b, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		if e.IsDir() || path.Ext(e.Name()) != ".md" {
+			continue
+		}
+
+		name := path.Join(PostsDir, e.Name())
+		data, err := fs.ReadFile(files, name)
+		if err != nil {
+			return nil, err
+		}
+
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+
+		// one broken post should not take the whole blog down, it is left out until fixed
+		p, err := ParsePost(name, data, info.ModTime())
+		if err != nil {
+			log.Printf("skipping post %s", err)
+			continue
+		}
+		if !p.Draft {
+			b.Posts = append(b.Posts, p)
+		}
+	}
+
+	slices.SortStableFunc(b.Posts, func(a, b *Post) int {
+		if c := b.Date.Compare(a.Date); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Slug, b.Slug)
+	})
+
+	for _, p := range b.Posts {
+		for _, tag := range p.Tags {
+			b.tags[tag] = append(b.tags[tag], p)
+		}
+	}
+
+	return b, nil
+}
+
+// Post finds a published post by its slug
+func (b *Blog) Post(slug string) *Post {
+	for _, p := range b.Posts {
+		if p.Slug == slug {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// Tags lists all tags of published posts in alphabetical order
+func (b *Blog) Tags() []string {
+	tags := make([]string, 0, len(b.tags))
+	for tag := range b.tags {
+		tags = append(tags, tag)
+	}
+	slices.Sort(tags)
+
+	return tags
+}
+
+// Tagged returns the published posts with the tag, newest first
+func (b *Blog) Tagged(tag string) []*Post {
+	return b.tags[tag]
+}
+
+// stamp changes whenever a post is added, removed or edited, so the blog is only parsed again then
+func stamp(files fs.FS) string {
+	entries, err := fs.ReadDir(files, PostsDir)
+	if err != nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil {
+			fmt.Fprintf(&sb, "%s %d %d\n", e.Name(), info.Size(), info.ModTime().UnixNano())
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,92 @@
+package blog
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// FeedSize is how many of the newest posts are in the Atom feed
+const FeedSize = 20
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// atomAuthor is required by RFC 4287 on the feed when entries have none
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Updated    string         `xml:"updated"`
+	Link       atomLink       `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomContent    `xml:"content"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// Feed renders the newest posts as an Atom feed by author, base is the site URL without
+// a trailing slash
+func (b *Blog) Feed(title, author, base string) ([]byte, error) {
+	feed := atomFeed{
+		Title:  title,
+		ID:     base + Prefix,
+		Author: atomAuthor{Name: author},
+		Links: []atomLink{
+			{Href: base + Prefix},
+			{Href: base + Prefix + "atom.xml", Rel: "self", Type: "application/atom+xml"},
+		},
+	}
+
+	updated := time.Unix(0, 0)
+	for i, p := range b.Posts {
+		if i == FeedSize {
+			break
+		}
+		if p.Date.After(updated) {
+			updated = p.Date
+		}
+
+		entry := atomEntry{
+			Title:   p.Title,
+			ID:      base + p.URL(),
+			Updated: p.Date.Format(time.RFC3339),
+			Link:    atomLink{Href: base + p.URL()},
+			Content: atomContent{Type: "html", Body: string(p.Content)},
+		}
+		for _, tag := range p.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	feed.Updated = updated.Format(time.RFC3339)
+
+	data, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), data...), nil
+}
@@ -0,0 +1,189 @@
+package blog
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ad/ton-site-ha/internal/render"
+)
+
+// Handler serves the blog under Prefix, posts are parsed again when the posts directory changes
+type Handler struct {
+	files fs.FS
+	set   *render.Set
+
+	mx    sync.Mutex
+	blog  *Blog
+	stamp string
+}
+
+// page is the Content of blog templates
+type page struct {
+	Title string
+	Post  *Post
+	Posts []*Post
+	Tag   string
+	Tags  []string
+
+	Page, Pages int
+	Prev, Next  string
+}
+
+// Funcs are the template functions blog templates need on top of render.Funcs
+var Funcs = map[string]any{
+	"tagURL": tagURL,
+}
+
+func tagURL(tag string) string {
+	return Prefix + "tags/" + url.PathEscape(tag) + "/"
+}
+
+// NewHandler serves posts from PostsDir of files with the blog/list.html and blog/post.html
+// templates of set, which should be created with Funcs
+func NewHandler(files fs.FS, set *render.Set) *Handler {
+	return &Handler{files: files, set: set}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b, err := h.load()
+	if err != nil {
+		log.Println("failed to load blog:", err.Error())
+		h.set.Error(w, r, http.StatusInternalServerError)
+		return
+	}
+
+	rest := strings.TrimPrefix(r.URL.Path, Prefix)
+	parts := strings.Split(strings.TrimSuffix(rest, "/"), "/")
+
+	switch {
+	case rest == "":
+		h.list(w, r, b.Posts, page{Title: "Blog", Tags: b.Tags()}, 1, Prefix)
+	case rest == "atom.xml":
+		h.feed(w, r, b)
+	case len(parts) == 2 && parts[0] == "page":
+		h.list(w, r, b.Posts, page{Title: "Blog", Tags: b.Tags()}, pageNumber(parts[1]), Prefix)
+	case parts[0] == "tags" && (len(parts) == 2 || len(parts) == 4 && parts[2] == "page"):
+		tag := parts[1]
+		posts := b.Tagged(tag)
+		if len(posts) == 0 {
+			h.set.Error(w, r, http.StatusNotFound)
+			return
+		}
+
+		n := 1
+		if len(parts) == 4 {
+			n = pageNumber(parts[3])
+		}
+		h.list(w, r, posts, page{Title: "Posts tagged " + tag, Tag: tag, Tags: b.Tags()}, n, tagURL(tag))
+	case len(parts) == 1:
+		p := b.Post(parts[0])
+		if p == nil {
+			h.set.Error(w, r, http.StatusNotFound)
+			return
+		}
+		if !strings.HasSuffix(rest, "/") {
+			http.Redirect(w, r, p.URL(), http.StatusMovedPermanently)
+			return
+		}
+
+		h.render(w, r, "post", page{Title: p.Title, Post: p, Tags: p.Tags})
+	default:
+		h.set.Error(w, r, http.StatusNotFound)
+	}
+}
+
+// load returns the blog, parsing the posts again if any of them changed
+func (h *Handler) load() (*Blog, error) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+
+	s := stamp(h.files)
+	if h.blog != nil && s == h.stamp {
+		return h.blog, nil
+	}
+
+	b, err := Load(h.files)
+	if err != nil {
+		return nil, err
+	}
+	h.blog, h.stamp = b, s
+
+	return b, nil
+}
+
+// list renders page n of posts, base is the URL of the first page
+func (h *Handler) list(w http.ResponseWriter, r *http.Request, posts []*Post, data page, n int, base string) {
+	data.Pages = max(1, (len(posts)+PageSize-1)/PageSize)
+	if n < 1 || n > data.Pages {
+		h.set.Error(w, r, http.StatusNotFound)
+		return
+	}
+
+	data.Page = n
+	data.Posts = posts[(n-1)*PageSize : min(n*PageSize, len(posts))]
+	if n == 2 {
+		data.Prev = base
+	} else if n > 2 {
+		data.Prev = base + "page/" + strconv.Itoa(n-1) + "/"
+	}
+	if n < data.Pages {
+		data.Next = base + "page/" + strconv.Itoa(n+1) + "/"
+	}
+
+	h.render(w, r, "list", data)
+}
+
+func (h *Handler) render(w http.ResponseWriter, r *http.Request, name string, content page) {
+	tmpl, err := h.set.Page("blog/" + name + ".html")
+	if err == nil && tmpl == nil {
+		err = errors.New("blog/" + name + ".html template not found")
+	}
+	if err != nil {
+		log.Print(err.Error())
+		h.set.Error(w, r, http.StatusInternalServerError)
+		return
+	}
+
+	data := h.set.Data(r, tmpl)
+	data.Title, data.Content = content.Title, content
+
+	h.set.Write(w, r, http.StatusOK, tmpl, data)
+}
+
+func (h *Handler) feed(w http.ResponseWriter, r *http.Request, b *Blog) {
+	// the site has no owner name, it is known by its domain or, without one, its address
+	author := r.Host
+	if host, _, err := net.SplitHostPort(author); err == nil {
+		author = host
+	}
+	if author == "" {
+		author = h.set.Data(r, nil).Site.Address
+	}
+
+	data, err := b.Feed("Blog", author, "http://"+r.Host)
+	if err != nil {
+		log.Print(err.Error())
+		h.set.Error(w, r, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	_, _ = w.Write(data)
+}
+
+// pageNumber returns 0 for anything but a positive number, which is then not found
+func pageNumber(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return 0
+	}
+
+	return n
+}
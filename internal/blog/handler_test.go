@@ -0,0 +1,141 @@
+package blog
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ad/ton-site-ha/internal/render"
+)
+
+func testFiles(posts int) fstest.MapFS {
+	files := fstest.MapFS{
+		"templates/layout.html":    {Data: []byte(`{{define "layout"}}<title>{{template "title" .}}</title>{{template "body" .}}{{end}}`)},
+		"templates/blog/list.html": {Data: []byte(`{{define "title"}}{{.Title}}{{end}}{{define "body"}}{{with .Content}}{{range .Posts}}[{{.Slug}}]{{end}} prev={{.Prev}} next={{.Next}}{{end}}{{end}}`)},
+		"templates/blog/post.html": {Data: []byte(`{{define "title"}}{{.Title}}{{end}}{{define "body"}}{{with .Content}}{{.Post.Content}}{{range .Tags}}<a href="{{tagURL .}}">{{.}}</a>{{end}}{{end}}{{end}}`)},
+		"posts/draft.md":           {Data: []byte("---\ntitle: Draft\ndraft: true\n---\nsecret")},
+	}
+
+	for i := 1; i <= posts; i++ {
+		tags := "all"
+		if i%2 == 0 {
+			tags = "all, even"
+		}
+		files[fmt.Sprintf("posts/post%02d.md", i)] = &fstest.MapFile{
+			Data: []byte(fmt.Sprintf("---\ntitle: Post %d\ndate: 2024-01-%02d\ntags: %s\n---\nbody of %d", i, i, tags, i)),
+		}
+	}
+
+	return files
+}
+
+func newTestHandler(t *testing.T, files fstest.MapFS) *Handler {
+	t.Helper()
+
+	set, err := render.NewSet(files, render.Site{}, false, Funcs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewHandler(files, set)
+}
+
+func get(t *testing.T, h http.Handler, path string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://site.adnl"+path, nil))
+	return w
+}
+
+func TestHandlerPages(t *testing.T) {
+	h := newTestHandler(t, testFiles(12))
+
+	cases := map[string]struct {
+		code int
+		body string
+	}{
+		"/blog/":                      {200, "[post12][post11][post10][post09][post08][post07][post06][post05][post04][post03] prev= next=/blog/page/2/"},
+		"/blog/page/2/":               {200, "[post02][post01] prev=/blog/ next="},
+		"/blog/page/3/":               {404, ""},
+		"/blog/page/x/":               {404, ""},
+		"/blog/tags/even/":            {200, "[post12][post10][post08][post06][post04][post02] prev= next="},
+		"/blog/tags/missing/":         {404, ""},
+		"/blog/post05/":               {200, "<p>body of 5</p>"},
+		"/blog/draft/":                {404, ""},
+		"/blog/post05/../../etc/pass": {404, ""},
+	}
+
+	for path, c := range cases {
+		w := get(t, h, path)
+		if w.Code != c.code {
+			t.Errorf("%s: expected %d, but got %d", path, c.code, w.Code)
+		}
+		if c.body != "" && !strings.Contains(w.Body.String(), c.body) {
+			t.Errorf("%s: expected %q in %q", path, c.body, w.Body.String())
+		}
+	}
+
+	if w := get(t, h, "/blog/post05"); w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/blog/post05/" {
+		t.Errorf("Expected redirect to the post, but got %d %s", w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestHandlerReloadsPosts(t *testing.T) {
+	files := testFiles(1)
+	h := newTestHandler(t, files)
+
+	if w := get(t, h, "/blog/post02/"); w.Code != http.StatusNotFound {
+		t.Fatalf("Expected 404 before the post is added, but got %d", w.Code)
+	}
+
+	files["posts/post02.md"] = &fstest.MapFile{Data: []byte("new post")}
+	if w := get(t, h, "/blog/post02/"); w.Code != http.StatusOK {
+		t.Errorf("Expected the new post to be served, but got %d", w.Code)
+	}
+}
+
+func TestFeed(t *testing.T) {
+	w := get(t, newTestHandler(t, testFiles(25)), "/blog/atom.xml")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/atom+xml") {
+		t.Fatalf("Unexpected response %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	var feed atomFeed
+	if err := xml.Unmarshal(w.Body.Bytes(), &feed); err != nil {
+		t.Fatal(err)
+	}
+	if len(feed.Entries) != FeedSize {
+		t.Errorf("Expected %d entries, but got %d", FeedSize, len(feed.Entries))
+	}
+	if feed.Entries[0].ID != "http://site.adnl/blog/post25/" || !strings.HasPrefix(feed.Updated, "2024-01-25") {
+		t.Errorf("Unexpected feed %s %s", feed.Entries[0].ID, feed.Updated)
+	}
+	if strings.Contains(w.Body.String(), "secret") {
+		t.Error("Expected drafts to be left out of the feed")
+	}
+	if feed.Author.Name != "site.adnl" {
+		t.Errorf("Expected the site as the feed author, but got %q", feed.Author.Name)
+	}
+}
+
+func TestHandlerSkipsBrokenPost(t *testing.T) {
+	files := testFiles(2)
+	files["posts/broken.md"] = &fstest.MapFile{Data: []byte("---\ntitle: Broken\ndate: someday\n---\nbody")}
+	files["posts/unclosed.md"] = &fstest.MapFile{Data: []byte("---\ntitle: Unclosed\nbody")}
+	h := newTestHandler(t, files)
+
+	if w := get(t, h, "/blog/"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "[post02][post01]") {
+		t.Errorf("Expected the other posts to be listed, but got %d %q", w.Code, w.Body.String())
+	}
+	if w := get(t, h, "/blog/post01/"); w.Code != http.StatusOK {
+		t.Errorf("Expected the other posts to be served, but got %d", w.Code)
+	}
+	if w := get(t, h, "/blog/broken/"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected the broken post to be left out, but got %d", w.Code)
+	}
+}
@@ -0,0 +1,98 @@
+package blog
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ad/ton-site-ha/internal/frontmatter"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+var markdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// Post is a Markdown file from the posts directory
+type Post struct {
+	Slug  string
+	Title string
+	Date  time.Time
+	Tags  []string
+	Draft bool
+
+	// Content is the rendered Markdown, raw HTML in posts is left out
+	Content template.HTML
+}
+
+// URL is where the post is served
+func (p *Post) URL() string {
+	return Prefix + p.Slug + "/"
+}
+
+// ParsePost reads the front matter and renders the Markdown of a post,
+// modTime is used when the front matter has no date
+func ParsePost(name string, data []byte, modTime time.Time) (*Post, error) {
+	p := &Post{
+		Slug: strings.TrimSuffix(path.Base(name), path.Ext(name)),
+		Date: modTime,
+	}
+
+	body, err := p.parseFrontMatter(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if p.Title == "" {
+		p.Title = p.Slug
+	}
+
+	var buf bytes.Buffer
+	if err := markdown.Convert(body, &buf); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	p.Content = template.HTML(buf.String())
+
+	return p, nil
+}
+
+// parseFrontMatter fills the post from title, date, tags and draft of the front matter
+func (p *Post) parseFrontMatter(data []byte) ([]byte, error) {
+	meta, body, err := frontmatter.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if title, ok := meta["title"]; ok {
+		p.Title = title
+	}
+
+	if date, ok := meta["date"]; ok {
+		if p.Date, err = parseDate(date); err != nil {
+			return nil, err
+		}
+	}
+
+	p.Tags = frontmatter.List(meta["tags"])
+
+	if draft, ok := meta["draft"]; ok {
+		if p.Draft, err = strconv.ParseBool(draft); err != nil {
+			return nil, fmt.Errorf("invalid draft value %q", draft)
+		}
+	}
+
+	return body, nil
+}
+
+func parseDate(value string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04", time.DateOnly} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date %q, expected 2006-01-02 or RFC3339 time", value)
+}
@@ -0,0 +1,56 @@
+package blog
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePost(t *testing.T) {
+	data := "---\r\ntitle: \"Hello, TON\"\r\ndate: 2024-05-01\r\ntags: [ton, home assistant]\r\ndraft: false\r\n---\r\n# Heading\n\nSome *text* <script>alert(1)</script>\n"
+
+	p, err := ParsePost("posts/hello.md", []byte(data), time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Slug != "hello" || p.Title != "Hello, TON" || p.URL() != "/blog/hello/" {
+		t.Errorf("Unexpected post %+v", p)
+	}
+	if p.Date.Format(time.DateOnly) != "2024-05-01" {
+		t.Errorf("Expected date 2024-05-01, but got %s", p.Date)
+	}
+	if !slices.Equal(p.Tags, []string{"ton", "home assistant"}) {
+		t.Errorf("Unexpected tags %q", p.Tags)
+	}
+	if !strings.Contains(string(p.Content), "<em>text</em>") || strings.Contains(string(p.Content), "<script>") {
+		t.Errorf("Unexpected content %s", p.Content)
+	}
+}
+
+func TestParsePostFrontMatter(t *testing.T) {
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	p, err := ParsePost("posts/plain.md", []byte("just text"), modTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Title != "plain" || !p.Date.Equal(modTime) {
+		t.Errorf("Expected defaults from the file, but got %+v", p)
+	}
+
+	p, err = ParsePost("posts/list.md", []byte("---\ntags:\n  - a\n  - b\ndraft: true\n---\n"), modTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(p.Tags, []string{"a", "b"}) || !p.Draft {
+		t.Errorf("Unexpected post %+v", p)
+	}
+
+	for _, data := range []string{"---\ntitle: x\n", "---\ndate: yesterday\n---\n", "---\ndraft: maybe\n---\n", "---\nno colon\n---\n"} {
+		if _, err := ParsePost("posts/bad.md", []byte(data), modTime); err == nil {
+			t.Errorf("Expected error for %q", data)
+		}
+	}
+}
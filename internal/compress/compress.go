@@ -0,0 +1,216 @@
+// Package compress negotiates Content-Encoding and compresses responses with gzip or deflate
+package compress
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Supported encodings, in order of preference when the client accepts both equally
+const (
+	Gzip    = "gzip"
+	Deflate = "deflate"
+)
+
+// MinSize is the smallest response worth compressing, when its length is known up front
+const MinSize = 256
+
+// Negotiate picks the encoding for the Accept-Encoding header, empty for identity.
+// q-values are respected, q=0 rules an encoding out, * stands for the encodings not listed.
+func Negotiate(accept string) string {
+	q := map[string]float64{}
+	wildcard := -1.0
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		value := 1.0
+		for _, param := range strings.Split(params, ";") {
+			k, v, ok := strings.Cut(param, "=")
+			if ok && strings.EqualFold(strings.TrimSpace(k), "q") {
+				f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+				if err != nil || f < 0 || f > 1 {
+					f = 0
+				}
+				value = f
+			}
+		}
+
+		if name == "*" {
+			wildcard = value
+		} else if name == "x-gzip" {
+			name = Gzip
+		}
+		q[name] = max(q[name], value)
+	}
+
+	weight := func(name string) float64 {
+		if v, ok := q[name]; ok {
+			return v
+		}
+		return max(wildcard, 0)
+	}
+
+	best, bestQ := "", 0.0
+	for _, name := range []string{Gzip, Deflate} {
+		if w := weight(name); w > bestQ {
+			best, bestQ = name, w
+		}
+	}
+
+	// identity is only preferred when asked for explicitly
+	if v, ok := q["identity"]; ok && v > bestQ {
+		return ""
+	}
+
+	return best
+}
+
+// Compressible reports if responses of the content type get smaller when compressed,
+// images other than SVG, archives, fonts in WOFF and media are compressed already
+func Compressible(contentType string) bool {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(t, "text/"),
+		strings.HasSuffix(t, "+json"),
+		strings.HasSuffix(t, "+xml"):
+		return true
+	}
+
+	switch t {
+	case "application/javascript", "application/json", "application/xml", "application/wasm",
+		"application/manifest+json", "font/ttf", "font/otf", "image/x-icon", "image/vnd.microsoft.icon":
+		return true
+	}
+
+	return false
+}
+
+var writers = map[string]*sync.Pool{
+	Gzip:    {New: func() any { return gzip.NewWriter(io.Discard) }},
+	Deflate: {New: func() any { return zlib.NewWriter(io.Discard) }},
+}
+
+type resetWriter interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+// Handler compresses the responses of next that are compressible and not encoded yet
+func Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vary(w.Header())
+
+		encoding := Negotiate(r.Header.Get("Accept-Encoding"))
+		if encoding == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &responseWriter{ResponseWriter: w, encoding: encoding, head: r.Method == http.MethodHead}
+		defer cw.Close()
+
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// vary marks the response as depending on Accept-Encoding, once
+func vary(h http.Header) {
+	for _, v := range h.Values("Vary") {
+		for _, field := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), "Accept-Encoding") {
+				return
+			}
+		}
+	}
+
+	h.Add("Vary", "Accept-Encoding")
+}
+
+// responseWriter decides on the first write whether to compress, by the headers set until then
+type responseWriter struct {
+	http.ResponseWriter
+	encoding string
+	head     bool
+
+	decided bool
+	w       resetWriter
+}
+
+func (c *responseWriter) WriteHeader(code int) {
+	if !c.decided {
+		c.decided = true
+		if c.shouldCompress(code) {
+			h := c.Header()
+			h.Set("Content-Encoding", c.encoding)
+			h.Del("Content-Length")
+			// the representation changed, so did its strong validator
+			if etag := h.Get("ETag"); strings.HasPrefix(etag, `"`) {
+				h.Set("ETag", "W/"+etag)
+			}
+
+			if !c.head {
+				c.w = writers[c.encoding].Get().(resetWriter)
+				c.w.Reset(c.ResponseWriter)
+			}
+		}
+	}
+
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *responseWriter) shouldCompress(code int) bool {
+	h := c.Header()
+	if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusNotModified ||
+		code == http.StatusPartialContent || h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" {
+		return false
+	}
+	if n, err := strconv.Atoi(h.Get("Content-Length")); err == nil && n < MinSize {
+		return false
+	}
+
+	return Compressible(h.Get("Content-Type"))
+}
+
+func (c *responseWriter) Write(p []byte) (int, error) {
+	if !c.decided {
+		if c.Header().Get("Content-Type") == "" {
+			c.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.w != nil {
+		return c.w.Write(p)
+	}
+
+	return c.ResponseWriter.Write(p)
+}
+
+// Close flushes the compressed stream and returns the writer to its pool
+func (c *responseWriter) Close() {
+	if c.w == nil {
+		return
+	}
+
+	_ = c.w.Close()
+	c.w.Reset(io.Discard)
+	writers[c.encoding].Put(c.w)
+	c.w = nil
+}
+
+func (c *responseWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
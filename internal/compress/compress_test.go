@@ -0,0 +1,203 @@
+package compress
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestNegotiate(t *testing.T) {
+	cases := map[string]string{
+		"":                             "",
+		"gzip":                         Gzip,
+		"GZIP":                         Gzip,
+		"x-gzip":                       Gzip,
+		"deflate":                      Deflate,
+		"gzip, deflate, br":            Gzip,
+		"deflate, gzip":                Gzip,
+		"gzip;q=0.5, deflate":          Deflate,
+		"gzip;q=0, deflate;q=0":        "",
+		"gzip;q=0":                     "",
+		"*":                            Gzip,
+		"*;q=0.1, gzip;q=0":            Deflate,
+		"identity":                     "",
+		"br":                           "",
+		"gzip;q=0.2, identity;q=0.5":   "",
+		"gzip ; q=0.8 , deflate;q=0.9": Deflate,
+		"gzip;q=bad, deflate;q=0.1":    Deflate,
+	}
+
+	for accept, want := range cases {
+		if got := Negotiate(accept); got != want {
+			t.Errorf("%q: expected %q, but got %q", accept, want, got)
+		}
+	}
+}
+
+func TestCompressible(t *testing.T) {
+	for _, ct := range []string{"text/html; charset=utf-8", "text/css", "application/javascript", "image/svg+xml", "application/atom+xml"} {
+		if !Compressible(ct) {
+			t.Errorf("Expected %s to be compressible", ct)
+		}
+	}
+	for _, ct := range []string{"image/png", "video/mp4", "application/zip", "font/woff2", ""} {
+		if Compressible(ct) {
+			t.Errorf("Expected %s not to be compressible", ct)
+		}
+	}
+}
+
+func decode(t *testing.T, encoding string, body io.Reader) string {
+	t.Helper()
+
+	var r io.Reader
+	var err error
+	switch encoding {
+	case Gzip:
+		r, err = gzip.NewReader(body)
+	case Deflate:
+		r, err = zlib.NewReader(body)
+	default:
+		r = body
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
+
+func TestHandler(t *testing.T) {
+	html := strings.Repeat("<p>hello</p>", 100)
+	h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/image":
+			w.Header().Set("Content-Type", "image/png")
+		case "/small":
+			w.Header().Set("Content-Type", "text/html")
+			w.Header().Set("Content-Length", "5")
+			_, _ = io.WriteString(w, "small")
+			return
+		}
+		_, _ = io.WriteString(w, html)
+	}))
+
+	cases := []struct {
+		path, accept, encoding string
+	}{
+		{"/", "gzip, deflate", Gzip},
+		{"/", "deflate", Deflate},
+		{"/", "", ""},
+		{"/image", "gzip", ""},
+		{"/small", "gzip", ""},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, c.path, nil)
+		r.Header.Set("Accept-Encoding", c.accept)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if got := w.Header().Get("Content-Encoding"); got != c.encoding {
+			t.Errorf("%s %q: expected encoding %q, but got %q", c.path, c.accept, c.encoding, got)
+		}
+		if w.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("%s %q: expected Vary: Accept-Encoding, but got %q", c.path, c.accept, w.Header().Values("Vary"))
+		}
+		if c.path == "/" {
+			if got := decode(t, c.encoding, w.Body); got != html {
+				t.Errorf("%s %q: body does not match", c.path, c.accept)
+			}
+		}
+	}
+}
+
+func TestStatic(t *testing.T) {
+	css := strings.Repeat("body { color: red }\n", 100)
+	files := fstest.MapFS{
+		"static/style.css":   {Data: []byte(css)},
+		"static/favicon.png": {Data: []byte("png")},
+	}
+
+	next := http.FileServer(http.FS(files))
+	s, err := NewStatic(files, "static", next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.variants) != 1 || len(s.variants["static/style.css"].data) != 2 {
+		t.Fatalf("Expected gzip and deflate variants of style.css only, but got %v", s.variants)
+	}
+
+	h := Handler(s)
+	get := func(accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/static/style.css", nil)
+		r.Header.Set("Accept-Encoding", accept)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	for _, encoding := range []string{Gzip, Deflate, ""} {
+		w := get(encoding)
+		if got := w.Header().Get("Content-Encoding"); got != encoding {
+			t.Errorf("Expected encoding %q, but got %q", encoding, got)
+		}
+		if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/css") {
+			t.Errorf("Expected text/css, but got %q", got)
+		}
+		if got := decode(t, encoding, w.Body); got != css {
+			t.Errorf("%q: body does not match", encoding)
+		}
+	}
+
+	// changed in the content directory since startup, compressed per request instead
+	files["static/style.css"] = &fstest.MapFile{Data: []byte(css + css)}
+	w := get(Gzip)
+	if got := decode(t, w.Header().Get("Content-Encoding"), w.Body); got != css+css {
+		t.Error("Expected the changed file, not the precompressed one")
+	}
+}
+
+func TestStaticRanges(t *testing.T) {
+	css := strings.Repeat("0123456789", 100)
+	files := fstest.MapFS{"static/style.css": {Data: []byte(css)}}
+
+	s, err := NewStatic(files, "static", http.FileServer(http.FS(files)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := Handler(s)
+
+	get := func(ranges string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/static/style.css", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		r.Header.Set("Range", ranges)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	// ranges are of the file, not of its gzip variant
+	w := get("bytes=10-14")
+	if w.Code != http.StatusPartialContent || w.Body.String() != "01234" || w.Header().Get("Content-Encoding") != "" {
+		t.Errorf("Expected 206 with bytes 10-14, but got %d %q %q", w.Code, w.Body.String(), w.Header().Get("Content-Encoding"))
+	}
+
+	w = get("bytes=0-1,-2")
+	if ct := w.Header().Get("Content-Type"); w.Code != http.StatusPartialContent || !strings.HasPrefix(ct, "multipart/byteranges") {
+		t.Errorf("Expected 206 multipart/byteranges, but got %d %q", w.Code, ct)
+	}
+
+	if w := get("bytes=5000-"); w.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Errorf("Expected 416, but got %d", w.Code)
+	}
+}
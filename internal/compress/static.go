@@ -0,0 +1,133 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ad/ton-site-ha/internal/httpcache"
+)
+
+// variant is a file compressed ahead of time, valid while the file keeps its size and mtime
+type variant struct {
+	size    int64
+	modTime time.Time
+	data    map[string][]byte
+}
+
+// Static serves files compressed at startup, so requests cost no CPU for compression.
+// Files not compressed ahead, or changed since, and range requests are passed to the next handler.
+type Static struct {
+	fs       fs.FS
+	next     http.Handler
+	variants map[string]*variant
+}
+
+// NewStatic compresses every compressible file under dir of files with each encoding,
+// keeping only the variants that are smaller than the file. URL paths map to files directly,
+// /static/style.css is static/style.css.
+func NewStatic(files fs.FS, dir string, next http.Handler) (*Static, error) {
+	s := &Static{fs: files, next: next, variants: map[string]*variant{}}
+
+	err := fs.WalkDir(files, dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !Compressible(mime.TypeByExtension(path.Ext(p))) {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		data, err := fs.ReadFile(files, p)
+		if err != nil {
+			return err
+		}
+
+		v := &variant{size: info.Size(), modTime: info.ModTime(), data: map[string][]byte{}}
+		for _, encoding := range []string{Gzip, Deflate} {
+			compressed, err := compress(encoding, data)
+			if err != nil {
+				return err
+			}
+			if len(compressed) < len(data) {
+				v.data[encoding] = compressed
+			}
+		}
+		if len(v.data) > 0 {
+			s.variants[p] = v
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *Static) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	v := s.variants[name]
+	// ranges are of the file itself, so a resumed download does not depend on the encoding
+	if v == nil || r.Header.Get("Range") != "" || !v.current(s.fs, name) {
+		s.next.ServeHTTP(w, r)
+		return
+	}
+
+	vary(w.Header())
+
+	encoding := Negotiate(r.Header.Get("Accept-Encoding"))
+	data, ok := v.data[encoding]
+	if !ok {
+		s.next.ServeHTTP(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Encoding", encoding)
+	if etag := w.Header().Get("ETag"); etag != "" {
+		w.Header().Set("ETag", httpcache.VariantETag(etag, encoding))
+	}
+	if ct := mime.TypeByExtension(path.Ext(name)); ct != "" {
+		w.Header().Set("Content-Type", ct)
+	}
+	http.ServeContent(w, r, name, v.modTime, bytes.NewReader(data))
+}
+
+// current reports if the file is still the one compressed, the content directory can change
+func (v *variant) current(files fs.FS, name string) bool {
+	info, err := fs.Stat(files, name)
+	return err == nil && info.Size() == v.size && info.ModTime().Equal(v.modTime)
+}
+
+// compress uses the best compression, it is done once and not per request
+func compress(encoding string, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	var w io.WriteCloser
+	var err error
+	if encoding == Gzip {
+		w, err = gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	} else {
+		w, err = zlib.NewWriterLevel(&buf, zlib.BestCompression)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
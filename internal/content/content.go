@@ -0,0 +1,139 @@
+// Package content layers the user's content directory over the site embedded in the binary
+package content
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Dir is a directory on disk, every file is opened with os.OpenInRoot,
+// so neither ../ nor symlinks can lead outside of it
+type Dir string
+
+func (d Dir) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	f, err := os.OpenInRoot(string(d), name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		// escaping symlinks and other errors from the root are not told apart from a missing file
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return f, err
+}
+
+// Hidden reports if any part of the path starts with a dot, like .env or .git/config
+func Hidden(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") && part != "." {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NoHidden leaves hidden files and directories out of fsys, a published directory often
+// has a .env or .git nobody meant to publish
+func NoHidden(fsys fs.FS) fs.FS {
+	return noHidden{fsys}
+}
+
+type noHidden struct {
+	fsys fs.FS
+}
+
+func (h noHidden) Open(name string) (fs.File, error) {
+	if Hidden(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return h.fsys.Open(name)
+}
+
+func (h noHidden) ReadDir(name string) ([]fs.DirEntry, error) {
+	if Hidden(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	entries, err := fs.ReadDir(h.fsys, name)
+
+	return slices.DeleteFunc(entries, func(e fs.DirEntry) bool { return Hidden(e.Name()) }), err
+}
+
+// Layers is a stack of file systems, a file is taken from the first layer that has it
+type Layers []fs.FS
+
+// New puts the content directory, if it exists, over the default layers
+func New(dir string, defaults ...fs.FS) Layers {
+	if dir != "" {
+		if st, err := os.Stat(dir); err == nil && st.IsDir() {
+			return append(Layers{Dir(dir)}, defaults...)
+		}
+	}
+
+	return defaults
+}
+
+// HasDir reports if the content directory is one of the layers, New leaves it out when it
+// does not exist and the defaults are never a Dir
+func (l Layers) HasDir() bool {
+	if len(l) == 0 {
+		return false
+	}
+
+	_, ok := l[0].(Dir)
+	return ok
+}
+
+func (l Layers) Open(name string) (fs.File, error) {
+	for _, layer := range l {
+		f, err := layer.Open(name)
+		if err == nil {
+			return f, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadDir merges the directory from all layers, upper layers hide entries with the same name
+func (l Layers) ReadDir(name string) ([]fs.DirEntry, error) {
+	var entries []fs.DirEntry
+	seen := map[string]bool{}
+	found := false
+
+	for _, layer := range l {
+		list, err := fs.ReadDir(layer, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		found = true
+		for _, e := range list {
+			if !seen[e.Name()] {
+				seen[e.Name()] = true
+				entries = append(entries, e)
+			}
+		}
+	}
+
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+
+	return entries, nil
+}
@@ -0,0 +1,99 @@
+package content
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLayers(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "templates"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "templates", "index.html"), []byte("mine"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	defaults := fstest.MapFS{
+		"templates/index.html":  {Data: []byte("default")},
+		"templates/layout.html": {Data: []byte("layout")},
+	}
+	files := New(dir, defaults)
+
+	for name, want := range map[string]string{"templates/index.html": "mine", "templates/layout.html": "layout"} {
+		data, err := fs.ReadFile(files, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("Expected %s to be %q, but got %q", name, want, data)
+		}
+	}
+
+	entries, err := fs.ReadDir(files, "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Name() != "index.html" || entries[1].Name() != "layout.html" {
+		t.Errorf("Expected merged directory, but got %v", entries)
+	}
+
+	if !files.HasDir() {
+		t.Error("Expected the content dir to be a layer")
+	}
+	if missing := New(filepath.Join(dir, "missing"), defaults); len(missing) != 1 || missing.HasDir() {
+		t.Error("Expected a missing content dir to be skipped")
+	}
+}
+
+func TestDirRefusesEscapes(t *testing.T) {
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	if err := os.Symlink(filepath.Join(outside, "secret"), filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "linkdir")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"link", "linkdir/secret", "../secret", "/etc/passwd"} {
+		if _, err := fs.ReadFile(Dir(dir), name); err == nil {
+			t.Errorf("Expected %s to be refused", name)
+		}
+	}
+
+	// an escaping file in the content dir falls back to the default instead of leaking
+	files := New(dir, fstest.MapFS{"link": {Data: []byte("default")}})
+	if data, err := fs.ReadFile(files, "link"); err != nil || string(data) != "default" {
+		t.Errorf("Expected default file, but got %q, %v", data, err)
+	}
+}
+
+func TestNoHidden(t *testing.T) {
+	files := NoHidden(fstest.MapFS{
+		"static/style.css":   {Data: []byte("css")},
+		"static/.env":        {Data: []byte("TOKEN=1")},
+		"static/.git/config": {Data: []byte("[core]")},
+	})
+
+	for _, name := range []string{"static/.env", "static/.git/config", "static/.git"} {
+		if _, err := fs.Stat(files, name); err == nil {
+			t.Errorf("Expected %s to be hidden", name)
+		}
+	}
+
+	entries, err := fs.ReadDir(files, "static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "style.css" {
+		t.Errorf("Expected only style.css, but got %v", entries)
+	}
+}
@@ -0,0 +1,169 @@
+package dhtcache
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/xssnick/tonutils-go/adnl"
+	"github.com/xssnick/tonutils-go/adnl/address"
+	"github.com/xssnick/tonutils-go/adnl/dht"
+	"github.com/xssnick/tonutils-go/adnl/keys"
+	"github.com/xssnick/tonutils-go/liteclient"
+)
+
+// MaxNodes limits how many recently used nodes are kept in the cache
+const MaxNodes = 256
+
+// Node is a DHT node as it is stored in the cache file
+type Node struct {
+	Addr string `json:"addr"`
+	Key  []byte `json:"key"`
+}
+
+// Gateway wraps the ADNL gateway used by the DHT client and remembers the nodes it talks to,
+// so the routing table can be restored after a restart
+type Gateway struct {
+	dht.Gateway
+
+	shared bool
+	nodes  []Node
+	mx     sync.Mutex
+}
+
+// Wrap returns a gateway for the DHT client, a shared gateway is not closed together with the client
+func Wrap(g dht.Gateway, shared bool) *Gateway {
+	return &Gateway{Gateway: g, shared: shared}
+}
+
+func (g *Gateway) RegisterClient(addr string, key ed25519.PublicKey) (adnl.Peer, error) {
+	g.remember(Node{Addr: addr, Key: key})
+	return g.Gateway.RegisterClient(addr, key)
+}
+
+func (g *Gateway) Close() error {
+	if g.shared {
+		return nil
+	}
+	return g.Gateway.Close()
+}
+
+// remember moves the node to the end of the list, dropping the least recently used ones
+func (g *Gateway) remember(n Node) {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	for i, known := range g.nodes {
+		if known.Addr == n.Addr && string(known.Key) == string(n.Key) {
+			g.nodes = append(g.nodes[:i], g.nodes[i+1:]...)
+			break
+		}
+	}
+
+	g.nodes = append(g.nodes, n)
+	if len(g.nodes) > MaxNodes {
+		g.nodes = g.nodes[len(g.nodes)-MaxNodes:]
+	}
+}
+
+// Save writes the recently used nodes to path
+func (g *Gateway) Save(path string) error {
+	g.mx.Lock()
+	data, err := json.Marshal(g.nodes)
+	g.mx.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Load reads nodes saved by Gateway.Save, a missing file is not an error
+func Load(path string) ([]*dht.Node, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var cached []Node
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return nil, err
+	}
+
+	var nodes []*dht.Node
+	for _, n := range cached {
+		ap, err := netip.ParseAddrPort(n.Addr)
+		if err != nil || !ap.Addr().Is4() || len(n.Key) != ed25519.PublicKeySize {
+			continue
+		}
+
+		nodes = append(nodes, &dht.Node{
+			ID: keys.PublicKeyED25519{Key: n.Key},
+			AddrList: &address.List{
+				Addresses: []*address.UDP{{IP: net.IP(ap.Addr().AsSlice()), Port: int32(ap.Port())}},
+			},
+		})
+	}
+
+	return nodes, nil
+}
+
+// NodesFromConfig returns the static DHT nodes of the network config
+func NodesFromConfig(cfg *liteclient.GlobalConfig) []*dht.Node {
+	var nodes []*dht.Node
+	for _, node := range cfg.DHT.StaticNodes.Nodes {
+		key, err := base64.StdEncoding.DecodeString(node.ID.Key)
+		if err != nil {
+			continue
+		}
+
+		sign, err := base64.StdEncoding.DecodeString(node.Signature)
+		if err != nil {
+			continue
+		}
+
+		n := &dht.Node{
+			ID: keys.PublicKeyED25519{Key: key},
+			AddrList: &address.List{
+				Version:    int32(node.AddrList.Version),
+				ReinitDate: int32(node.AddrList.ReinitDate),
+				Priority:   int32(node.AddrList.Priority),
+				ExpireAt:   int32(node.AddrList.ExpireAt),
+			},
+			Version:   int32(node.Version),
+			Signature: sign,
+		}
+
+		for _, addr := range node.AddrList.Addrs {
+			ip := make(net.IP, 4)
+			binary.BigEndian.PutUint32(ip, uint32(int32(addr.IP)))
+			n.AddrList.Addresses = append(n.AddrList.Addresses, &address.UDP{
+				IP:   ip,
+				Port: int32(addr.Port),
+			})
+		}
+
+		if len(n.AddrList.Addresses) > 0 {
+			nodes = append(nodes, n)
+		}
+	}
+
+	return nodes
+}
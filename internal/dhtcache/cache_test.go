@@ -0,0 +1,55 @@
+package dhtcache
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/xssnick/tonutils-go/adnl/keys"
+)
+
+func TestSaveLoad(t *testing.T) {
+	g := Wrap(nil, true)
+	for i := 0; i < MaxNodes+10; i++ {
+		g.remember(Node{Addr: fmt.Sprintf("10.0.%d.%d:3000", i/256, i%256), Key: bytes.Repeat([]byte{byte(i)}, ed25519.PublicKeySize)})
+	}
+	// already known node moves to the end instead of being duplicated
+	g.remember(Node{Addr: "10.0.0.20:3000", Key: bytes.Repeat([]byte{20}, ed25519.PublicKeySize)})
+	g.remember(Node{Addr: "bad address", Key: []byte{1}})
+
+	path := filepath.Join(t.TempDir(), "nodes.json")
+	if err := g.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	nodes, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != MaxNodes-1 {
+		t.Fatalf("Expected %d valid nodes, but got %d", MaxNodes-1, len(nodes))
+	}
+
+	last := nodes[len(nodes)-1]
+	if addr := last.AddrList.Addresses[0]; addr.IP.String() != "10.0.0.20" || addr.Port != 3000 {
+		t.Errorf("Expected last used node to be 10.0.0.20:3000, but got %s:%d", addr.IP, addr.Port)
+	}
+	if key := last.ID.(keys.PublicKeyED25519).Key; !bytes.Equal(key, bytes.Repeat([]byte{20}, ed25519.PublicKeySize)) {
+		t.Errorf("Unexpected key %x", key)
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	nodes, err := Load(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil || nodes != nil {
+		t.Errorf("Expected no nodes and no error, but got %v, %v", nodes, err)
+	}
+}
+
+func TestSharedGatewayIsNotClosed(t *testing.T) {
+	if err := Wrap(nil, true).Close(); err != nil {
+		t.Error(err)
+	}
+}
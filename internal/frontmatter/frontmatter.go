@@ -0,0 +1,72 @@
+// Package frontmatter reads the `key: value` block between two --- lines at the start of pages and posts
+package frontmatter
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Parse returns the front matter values with lower case keys and the rest of the file.
+// Values may be quoted, a key with an empty value followed by `- item` lines gets the
+// items joined with ", ". Files without front matter are returned as is.
+func Parse(data []byte) (map[string]string, []byte, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !bytes.HasPrefix(data, []byte("---\n")) && !bytes.HasPrefix(data, []byte("---\r\n")) {
+		return nil, data, nil
+	}
+
+	meta := map[string]string{}
+	_, rest, _ := bytes.Cut(data, []byte("\n"))
+
+	var key string
+	for len(rest) > 0 {
+		var raw []byte
+		raw, rest, _ = bytes.Cut(rest, []byte("\n"))
+		line := strings.TrimSpace(string(raw))
+
+		switch {
+		case line == "---":
+			return meta, rest, nil
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "- ") && key != "":
+			item := Unquote(strings.TrimSpace(strings.TrimPrefix(line, "- ")))
+			if meta[key] != "" {
+				item = meta[key] + ", " + item
+			}
+			meta[key] = item
+			continue
+		}
+
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid front matter line %q", line)
+		}
+		key = strings.ToLower(strings.TrimSpace(k))
+		meta[key] = Unquote(strings.TrimSpace(v))
+	}
+
+	return nil, nil, fmt.Errorf("front matter is not closed with ---")
+}
+
+// List splits a `[a, b]` or `a, b` value
+func List(value string) []string {
+	var items []string
+	for _, item := range strings.Split(strings.Trim(value, "[]"), ",") {
+		if item = Unquote(strings.TrimSpace(item)); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// Unquote removes matching single or double quotes around a value
+func Unquote(v string) string {
+	if len(v) >= 2 && (v[0] == '"' && v[len(v)-1] == '"' || v[0] == '\'' && v[len(v)-1] == '\'') {
+		return v[1 : len(v)-1]
+	}
+
+	return v
+}
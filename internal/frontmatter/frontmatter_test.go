@@ -0,0 +1,36 @@
+package frontmatter
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	meta, body, err := Parse([]byte("---\r\ntitle: 'Hello: world'\r\n# comment\r\ntags:\r\n  - a\r\n  - \"b\"\r\n---\r\nbody\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta["title"] != "Hello: world" || meta["tags"] != "a, b" {
+		t.Errorf("Unexpected front matter %q", meta)
+	}
+	if string(body) != "body\n" {
+		t.Errorf("Unexpected body %q", body)
+	}
+
+	meta, body, err = Parse([]byte("no front matter"))
+	if err != nil || meta != nil || string(body) != "no front matter" {
+		t.Errorf("Expected the file as is, but got %q %q %v", meta, body, err)
+	}
+
+	for _, data := range []string{"---\ntitle: x\n", "---\njust text\n---\n"} {
+		if _, _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("Expected error for %q", data)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	if got := List(`[a, "b c", ]`); !slices.Equal(got, []string{"a", "b c"}) {
+		t.Errorf("Unexpected list %q", got)
+	}
+}
@@ -0,0 +1,97 @@
+package httpcache
+
+import (
+	"io/fs"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Files tags files with the hash of their content. Embedded files have no modification
+// time, so the hash is the only validator they can have.
+type Files struct {
+	fs   fs.FS
+	next http.Handler
+
+	mx   sync.Mutex
+	tags map[string]fileTag
+}
+
+// fileTag is valid while the file keeps its size and mtime
+type fileTag struct {
+	size    int64
+	modTime time.Time
+	etag    string
+}
+
+// NewFiles hashes every file under dir of files now, files added or changed later are
+// hashed on their first request. URL paths map to files directly, /static/a.css is static/a.css.
+func NewFiles(files fs.FS, dir string, next http.Handler) (*Files, error) {
+	f := &Files{fs: files, next: next, tags: map[string]fileTag{}}
+
+	err := fs.WalkDir(files, dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		_, err = f.hash(p, info)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (f *Files) hash(name string, info fs.FileInfo) (string, error) {
+	data, err := fs.ReadFile(f.fs, name)
+	if err != nil {
+		return "", err
+	}
+
+	etag := ETag(data)
+	f.mx.Lock()
+	f.tags[name] = fileTag{size: info.Size(), modTime: info.ModTime(), etag: etag}
+	f.mx.Unlock()
+
+	return etag, nil
+}
+
+// ETag returns the validator of the file, empty if there is no such file
+func (f *Files) ETag(name string) string {
+	info, err := fs.Stat(f.fs, name)
+	if err != nil || info.IsDir() {
+		return ""
+	}
+
+	f.mx.Lock()
+	tag, ok := f.tags[name]
+	f.mx.Unlock()
+
+	if !ok || tag.size != info.Size() || !tag.modTime.Equal(info.ModTime()) {
+		etag, err := f.hash(name, info)
+		if err != nil {
+			return ""
+		}
+		return etag
+	}
+
+	return tag.etag
+}
+
+// ServeHTTP sets the ETag and lets next answer, http.ServeContent and http.FileServer
+// check If-None-Match against it and reply 304
+func (f *Files) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if etag := f.ETag(strings.TrimPrefix(r.URL.Path, "/")); etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+
+	f.next.ServeHTTP(w, r)
+}
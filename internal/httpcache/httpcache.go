@@ -0,0 +1,84 @@
+// Package httpcache sets the validators and Cache-Control of responses, so repeat visits
+// are answered with 304 Not Modified instead of the whole response
+package httpcache
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ETag is a strong validator for the content, a truncated SHA-256
+func ETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+}
+
+// VariantETag is the validator of an encoded variant of the response tagged etag,
+// every representation needs its own
+func VariantETag(etag, encoding string) string {
+	if !strings.HasSuffix(etag, `"`) {
+		return etag
+	}
+
+	return strings.TrimSuffix(etag, `"`) + "-" + encoding + `"`
+}
+
+// Rule sets Cache-Control to Value for the paths starting with Prefix
+type Rule struct {
+	Prefix string
+	Value  string
+}
+
+// Rules are matched by the longest prefix
+type Rules []Rule
+
+// ParseRules reads rules like `/static/=public, max-age=86400; /=no-cache`
+func ParseRules(s string) (Rules, error) {
+	var rules Rules
+	for _, part := range strings.Split(s, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		prefix, value, ok := strings.Cut(part, "=")
+		prefix, value = strings.TrimSpace(prefix), strings.TrimSpace(value)
+		if !ok || value == "" {
+			return nil, fmt.Errorf("expected PREFIX=VALUE, got %q", part)
+		}
+		if !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("prefix should start with /, got %q", prefix)
+		}
+
+		rules = append(rules, Rule{Prefix: prefix, Value: value})
+	}
+
+	return rules, nil
+}
+
+// Lookup returns the Cache-Control for the path, empty if no rule matches
+func (rules Rules) Lookup(path string) string {
+	var best Rule
+	for _, rule := range rules {
+		if strings.HasPrefix(path, rule.Prefix) && len(rule.Prefix) > len(best.Prefix) {
+			best = rule
+		}
+	}
+
+	return best.Value
+}
+
+// CacheControl sets Cache-Control by the rules, handlers can still replace it,
+// error pages are sent with no-store
+func CacheControl(rules Rules, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if value := rules.Lookup(r.URL.Path); value != "" {
+			w.Header().Set("Cache-Control", value)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
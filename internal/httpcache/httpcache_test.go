@@ -0,0 +1,90 @@
+package httpcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestRules(t *testing.T) {
+	rules, err := ParseRules("/static/=public, max-age=86400; /=no-cache;/static/img/ = max-age=60")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]string{
+		"/":                 "no-cache",
+		"/blog/":            "no-cache",
+		"/static/style.css": "public, max-age=86400",
+		"/static/img/a.png": "max-age=60",
+	}
+	for path, want := range cases {
+		if got := rules.Lookup(path); got != want {
+			t.Errorf("%s: expected %q, but got %q", path, want, got)
+		}
+	}
+
+	if got := (Rules{}).Lookup("/"); got != "" {
+		t.Errorf("Expected no Cache-Control without rules, but got %q", got)
+	}
+
+	for _, bad := range []string{"static/=no-cache", "/static/", "/=", "/=no-cache; nonsense"} {
+		if _, err := ParseRules(bad); err == nil {
+			t.Errorf("Expected error for %q", bad)
+		}
+	}
+}
+
+func TestFiles(t *testing.T) {
+	files := fstest.MapFS{
+		// embedded files look like this, no modification time
+		"static/style.css": {Data: []byte("body {}")},
+	}
+
+	f, err := NewFiles(files, "static", http.FileServer(http.FS(files)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, _ := ParseRules("/static/=max-age=60")
+	h := CacheControl(rules, f)
+
+	get := func(etag string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/static/style.css", nil)
+		if etag != "" {
+			r.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := get("")
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag != ETag([]byte("body {}")) {
+		t.Fatalf("Expected 200 with the content hash, but got %d %q", w.Code, etag)
+	}
+	if got := w.Header().Get("Cache-Control"); got != "max-age=60" {
+		t.Errorf("Expected Cache-Control from the rules, but got %q", got)
+	}
+
+	if w := get(etag); w.Code != http.StatusNotModified {
+		t.Errorf("Expected 304, but got %d", w.Code)
+	}
+	if w := get(`W/` + etag); w.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for the weak tag of a compressed response, but got %d", w.Code)
+	}
+
+	// edited in the content directory
+	files["static/style.css"] = &fstest.MapFile{Data: []byte("body { color: red }"), ModTime: time.Now()}
+	if w := get(etag); w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Errorf("Expected 200 with a new ETag after a change, but got %d %q", w.Code, w.Header().Get("ETag"))
+	}
+}
+
+func TestVariantETag(t *testing.T) {
+	if got := VariantETag(`"abc"`, "gzip"); got != `"abc-gzip"` {
+		t.Errorf("Expected \"abc-gzip\", but got %s", got)
+	}
+}
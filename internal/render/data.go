@@ -0,0 +1,89 @@
+// Package render holds what site templates see: the data of a request and the function library
+package render
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	rldphttp "github.com/ad/ton-site-ha/internal/rldphttp"
+)
+
+// Data is the dot of every site template
+type Data struct {
+	Request Request
+	Site    Site
+	Now     time.Time
+
+	// Page is the front matter of the page template
+	Page map[string]string
+	// Title is the `title` of the front matter unless the handler sets its own
+	Title string
+	// Content is what the handler renders, the posts of a blog page for example
+	Content any
+
+	visitor Visitor
+	// personal is set once the page read .Visitor, it differs for every visitor then
+	personal bool
+}
+
+// Visitor is who asked for the page. A page reading it is sent with Cache-Control: private,
+// so neither the response cache nor proxies show one visitor's address to another.
+func (d *Data) Visitor() Visitor {
+	d.personal = true
+	return d.visitor
+}
+
+// Personal reports if the rendered page showed visitor data
+func (d *Data) Personal() bool {
+	return d.personal
+}
+
+// Request is the part of the HTTP request templates may use
+type Request struct {
+	// ID is the RLDP request id in hex, to find the request in the logs
+	ID     string
+	Method string
+	Host   string
+	Path   string
+	Query  url.Values
+}
+
+// Visitor is who asked for the page, as reported by the RLDP server
+type Visitor struct {
+	// ADNL is the address of the visitor's node, empty when the site is not served over RLDP
+	ADNL string
+	IP   string
+}
+
+// Site describes the site itself
+type Site struct {
+	// Address is the .adnl address of the site
+	Address string
+	Version string
+}
+
+// NewData collects the data of r for a page with the given front matter
+func NewData(r *http.Request, site Site, page map[string]string) *Data {
+	if page == nil {
+		page = map[string]string{}
+	}
+
+	return &Data{
+		Request: Request{
+			ID:     rldphttp.RequestID(r.Context()),
+			Method: r.Method,
+			Host:   r.Host,
+			Path:   r.URL.Path,
+			Query:  r.URL.Query(),
+		},
+		visitor: Visitor{
+			ADNL: r.Header.Get("X-Adnl-Id"),
+			IP:   r.Header.Get("X-Adnl-Ip"),
+		},
+		Site:  site,
+		Now:   time.Now(),
+		Page:  page,
+		Title: page["title"],
+	}
+}
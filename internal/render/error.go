@@ -0,0 +1,102 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ad/ton-site-ha/internal/httpcache"
+)
+
+// ErrorsDir holds the error pages in TemplatesDir, named by status code like errors/404.html
+const ErrorsDir = "errors"
+
+// Error is the Content of error pages
+type Error struct {
+	Code int
+	Text string
+}
+
+// Render renders the page into memory, so nothing is sent when a template fails half way
+func (s *Set) Render(p *Page, data *Data) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := s.Execute(&buf, p, data); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Write sends the whole page with the status code, or the 500 page if it fails to render.
+// A 200 page is tagged with the hash of its content, a repeat request gets 304 Not Modified.
+func (s *Set) Write(w http.ResponseWriter, r *http.Request, code int, p *Page, data *Data) {
+	body, err := s.Render(p, data)
+	if err != nil {
+		log.Printf("failed to render %s: %s", p.Name, err)
+		s.Error(w, r, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if data != nil && data.Personal() {
+		w.Header().Set("Cache-Control", "private")
+	}
+	if code == http.StatusOK {
+		w.Header().Set("ETag", httpcache.ETag(body))
+		http.ServeContent(w, r, p.Name, time.Time{}, bytes.NewReader(body))
+		return
+	}
+
+	w.WriteHeader(code)
+	_, _ = w.Write(body)
+}
+
+// Error sends the error page for the status code, plain text if there is no such page
+// or it fails to render. The 500 page can show the request id to report the problem.
+func (s *Set) Error(w http.ResponseWriter, r *http.Request, code int) {
+	text := http.StatusText(code)
+
+	body, err := s.renderError(r, code, text)
+	if err != nil {
+		log.Printf("failed to render %d page: %s", code, err)
+
+		if id := s.Data(r, nil).Request.ID; id != "" && code == http.StatusInternalServerError {
+			text += "\nrequest id " + id
+		}
+		http.Error(w, text, code)
+		return
+	}
+
+	// the page is returned instead of whatever the handler meant to send
+	for _, h := range []string{"Content-Encoding", "Content-Length", "ETag", "Last-Modified"} {
+		w.Header().Del(h)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_, _ = w.Write(body)
+}
+
+func (s *Set) renderError(r *http.Request, code int, text string) ([]byte, error) {
+	name := fmt.Sprintf("%s/%d.html", ErrorsDir, code)
+	p, err := s.Page(name)
+	if err != nil {
+		// a broken template in dev mode, the error page of the last good parse still works
+		s.mx.Lock()
+		p = s.pages[name]
+		s.mx.Unlock()
+	}
+	if p == nil {
+		return nil, fmt.Errorf("no %s template", name)
+	}
+
+	data := s.Data(r, p)
+	data.Content = Error{Code: code, Text: text}
+	if data.Title == "" {
+		data.Title = text
+	}
+
+	return s.Render(p, data)
+}
@@ -0,0 +1,136 @@
+package render
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ad/ton-site-ha/internal/respcache"
+	rldphttp "github.com/ad/ton-site-ha/internal/rldphttp"
+)
+
+func errorFiles() fstest.MapFS {
+	return fstest.MapFS{
+		"templates/layout.html":     {Data: []byte(`{{define "layout"}}<title>{{.Title}}</title>{{template "body" .}}{{end}}`)},
+		"templates/errors/404.html": {Data: []byte(`{{define "body"}}{{.Content.Code}} {{.Request.Path}}{{end}}`)},
+		"templates/errors/500.html": {Data: []byte(`{{define "body"}}id={{.Request.ID}}{{end}}`)},
+		"templates/broken.html":     {Data: []byte(`{{define "body"}}{{index .Page "x" "y"}}{{end}}`)},
+	}
+}
+
+func TestError(t *testing.T) {
+	set := testSet(t, errorFiles(), false)
+
+	w := httptest.NewRecorder()
+	set.Error(w, httptest.NewRequest("GET", "/missing", nil), http.StatusNotFound)
+	if w.Code != http.StatusNotFound || w.Body.String() != "<title>Not Found</title>404 /missing" {
+		t.Errorf("Expected the 404 page, but got %d %q", w.Code, w.Body.String())
+	}
+
+	// no template for 503, plain text is sent
+	w = httptest.NewRecorder()
+	set.Error(w, httptest.NewRequest("GET", "/", nil), http.StatusServiceUnavailable)
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "Service Unavailable") {
+		t.Errorf("Expected plain 503, but got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestWriteBrokenPage(t *testing.T) {
+	set := testSet(t, errorFiles(), false)
+	page, _ := set.Page("broken.html")
+
+	r := httptest.NewRequest("GET", "/broken.html", nil)
+	r = r.WithContext(rldphttp.WithRequestID(r.Context(), []byte{0xab, 0xcd}))
+
+	w := httptest.NewRecorder()
+	set.Write(w, r, http.StatusOK, page, set.Data(r, page))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected 500, but got %d", w.Code)
+	}
+	// nothing of the broken page is sent, only the 500 page
+	if w.Body.String() != "<title>Internal Server Error</title>id=abcd" {
+		t.Errorf("Expected the 500 page with the request id, but got %q", w.Body.String())
+	}
+}
+
+func TestErrorDevModeBroken(t *testing.T) {
+	files := errorFiles()
+	set := testSet(t, files, true)
+
+	files["templates/index.html"] = &fstest.MapFile{Data: []byte(`{{define "body"}}{{end`)}
+	if _, err := set.Page("index.html"); err == nil {
+		t.Fatal("Expected error for a broken template")
+	}
+
+	w := httptest.NewRecorder()
+	set.Error(w, httptest.NewRequest("GET", "/gone", nil), http.StatusNotFound)
+	if w.Body.String() != "<title>Not Found</title>404 /gone" {
+		t.Errorf("Expected the last good 404 page, but got %q", w.Body.String())
+	}
+}
+
+func TestWriteNotModified(t *testing.T) {
+	files := errorFiles()
+	files["templates/index.html"] = &fstest.MapFile{Data: []byte(`{{define "body"}}index{{end}}`)}
+	set := testSet(t, files, false)
+	page, _ := set.Page("index.html")
+
+	r := httptest.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	set.Write(w, r, http.StatusOK, page, set.Data(r, page))
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("Expected 200 with an ETag, but got %d %q", w.Code, etag)
+	}
+
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	set.Write(w, r, http.StatusOK, page, set.Data(r, page))
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("Expected 304 without a body, but got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestWriteVisitorPrivate(t *testing.T) {
+	files := errorFiles()
+	files["templates/visitor.html"] = &fstest.MapFile{Data: []byte(`{{define "body"}}you are {{.Visitor.ADNL}}{{end}}`)}
+	files["templates/index.html"] = &fstest.MapFile{Data: []byte(`{{define "body"}}index{{end}}`)}
+	set := testSet(t, files, false)
+
+	renders := 0
+	h := respcache.New(1 << 20).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		renders++
+		page, _ := set.Page(strings.TrimPrefix(r.URL.Path, "/"))
+		set.Write(w, r, http.StatusOK, page, set.Data(r, page))
+	}))
+
+	for _, visitor := range []string{"alice", "bob"} {
+		r := httptest.NewRequest("GET", "/visitor.html", nil)
+		r.Header.Set("X-Adnl-Id", visitor)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if !strings.Contains(w.Body.String(), "you are "+visitor) {
+			t.Errorf("Expected the page of %s, but got %q", visitor, w.Body.String())
+		}
+		if cc := w.Header().Get("Cache-Control"); cc != "private" {
+			t.Errorf("Expected Cache-Control private, but got %q", cc)
+		}
+	}
+	if renders != 2 {
+		t.Errorf("Expected the page rendered for each visitor, but got %d renders", renders)
+	}
+
+	for range 2 {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/index.html", nil))
+		if w.Header().Get("Cache-Control") != "" {
+			t.Errorf("Expected pages without visitor data to stay cacheable, but got %q", w.Header().Get("Cache-Control"))
+		}
+	}
+	if renders != 3 {
+		t.Errorf("Expected the page without visitor data to be cached, but got %d renders", renders)
+	}
+}
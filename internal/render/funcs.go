@@ -0,0 +1,78 @@
+package render
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/xssnick/tonutils-go/address"
+)
+
+// Funcs is the function library of site templates
+var Funcs = map[string]any{
+	"date":         formatDate,
+	"isoDate":      isoDate,
+	"size":         formatSize,
+	"tonAddress":   tonAddress,
+	"shortAddress": shortAddress,
+	"static":       static,
+}
+
+// formatDate formats t with a Go layout, `{{date "2 Jan 2006" .Now}}`
+func formatDate(layout string, t time.Time) string {
+	return t.Format(layout)
+}
+
+// isoDate is the RFC 3339 form for datetime attributes and feeds
+func isoDate(t time.Time) string {
+	return t.Format(time.RFC3339)
+}
+
+// formatSize prints a byte count in binary units, `{{size 1536}}` is 1.5 KiB
+func formatSize(n int64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	value, unit := float64(n), 0
+	for value >= 1024 && unit < 6 {
+		value /= 1024
+		unit++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", value, "KMGTPE"[unit-1])
+}
+
+// tonAddress normalizes a TON address in any form to the user friendly one
+func tonAddress(s string) (string, error) {
+	addr, err := parseTONAddress(s)
+	if err != nil {
+		return "", err
+	}
+
+	return addr.String(), nil
+}
+
+// shortAddress keeps the start and the end of a TON address for compact display
+func shortAddress(s string) (string, error) {
+	addr, err := tonAddress(s)
+	if err != nil {
+		return "", err
+	}
+
+	return addr[:6] + "…" + addr[len(addr)-6:], nil
+}
+
+func parseTONAddress(s string) (*address.Address, error) {
+	if strings.Contains(s, ":") {
+		return address.ParseRawAddr(s)
+	}
+
+	return address.ParseAddr(s)
+}
+
+// static links to a file in the static directory, `{{static "style.css"}}`
+func static(name string) string {
+	return path.Join("/static", path.Clean("/"+name))
+}
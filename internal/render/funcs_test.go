@@ -0,0 +1,59 @@
+package render
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func TestFuncs(t *testing.T) {
+	cases := map[string]string{
+		`{{size 512}}`:                  "512 B",
+		`{{size 1536}}`:                 "1.5 KiB",
+		`{{size 5368709120}}`:           "5.0 GiB",
+		`{{date "2006-01-02" .Now}}`:    "2024-05-01",
+		`{{isoDate .Now}}`:              "2024-05-01T10:00:00Z",
+		`{{static "style.css"}}`:        "/static/style.css",
+		`{{static "../../etc/passwd"}}`: "/static/etc/passwd",
+		`{{tonAddress "EQBYTuYbLf8INxFtD8tQeNk5ZLy-nAX9ahQbG_yl1qQ-GEMS"}}`:   "EQBYTuYbLf8INxFtD8tQeNk5ZLy-nAX9ahQbG_yl1qQ-GEMS",
+		`{{shortAddress "EQBYTuYbLf8INxFtD8tQeNk5ZLy-nAX9ahQbG_yl1qQ-GEMS"}}`: "EQBYTu…Q-GEMS",
+	}
+
+	data := &Data{Now: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)}
+	for text, want := range cases {
+		var sb strings.Builder
+		if err := template.Must(template.New("").Funcs(Funcs).Parse(text)).Execute(&sb, data); err != nil {
+			t.Errorf("%s: %s", text, err)
+			continue
+		}
+		if sb.String() != want {
+			t.Errorf("%s: expected %q, but got %q", text, want, sb.String())
+		}
+	}
+
+	if err := template.Must(template.New("").Funcs(Funcs).Parse(`{{tonAddress "nope"}}`)).Execute(&strings.Builder{}, nil); err == nil {
+		t.Error("Expected error for an invalid TON address")
+	}
+}
+
+func TestNewData(t *testing.T) {
+	r := httptest.NewRequest("GET", "http://site.adnl/page.html?q=1", nil)
+	r.Header.Set("X-Adnl-Id", "visitor")
+	r.Header.Set("X-Adnl-Ip", "1.2.3.4")
+
+	d := NewData(r, Site{Address: "site.adnl", Version: "1.0"}, map[string]string{"title": "Page"})
+	if d.Request.Path != "/page.html" || d.Request.Query.Get("q") != "1" || d.Request.Host != "site.adnl" {
+		t.Errorf("Unexpected request %+v", d.Request)
+	}
+	if d.Personal() {
+		t.Error("Expected the data not to be personal before .Visitor is read")
+	}
+	if v := d.Visitor(); v.ADNL != "visitor" || v.IP != "1.2.3.4" || !d.Personal() {
+		t.Errorf("Unexpected visitor %+v", v)
+	}
+	if d.Title != "Page" || d.Site.Version != "1.0" || d.Now.IsZero() {
+		t.Errorf("Unexpected data %+v", d)
+	}
+}
@@ -0,0 +1,198 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"maps"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/ad/ton-site-ha/internal/frontmatter"
+)
+
+// TemplatesDir is where site templates are in the content
+const TemplatesDir = "templates"
+
+// DefaultLayout is the template pages are rendered into unless their front matter sets `layout`,
+// `layout: none` renders the page on its own
+const DefaultLayout = "layout"
+
+// Set is every page of TemplatesDir parsed once together with the layouts and partials.
+// templates/layout.html, templates/layouts/ and templates/partials/ are shared by all pages,
+// partials are used by their path, `{{template "partials/nav.html" .}}`.
+type Set struct {
+	files fs.FS
+	site  Site
+	funcs template.FuncMap
+	dev   bool
+
+	mx    sync.Mutex
+	pages map[string]*Page
+	stamp string
+}
+
+// Page is a parsed page template, Name is its path in TemplatesDir
+type Page struct {
+	Name   string
+	Meta   map[string]string
+	Layout string
+	tmpl   *template.Template
+}
+
+// NewSet parses all templates of files and reports every broken one at once. funcs are added
+// to Funcs. In dev mode the set is parsed again when any template changes.
+func NewSet(files fs.FS, site Site, dev bool, funcs map[string]any) (*Set, error) {
+	s := &Set{files: files, site: site, funcs: template.FuncMap{}, dev: dev}
+	maps.Copy(s.funcs, Funcs)
+	maps.Copy(s.funcs, funcs)
+
+	pages, err := s.parse()
+	if err != nil {
+		return nil, err
+	}
+	s.pages, s.stamp = pages, s.currentStamp()
+
+	return s, nil
+}
+
+// Page returns the page template by its path in TemplatesDir, nil if there is no such page
+func (s *Set) Page(name string) (*Page, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if s.dev {
+		if stamp := s.currentStamp(); stamp != s.stamp {
+			pages, err := s.parse()
+			if err != nil {
+				return nil, err
+			}
+			s.pages, s.stamp = pages, stamp
+		}
+	}
+
+	return s.pages[name], nil
+}
+
+// Data collects the data of r for the page, p may be nil
+func (s *Set) Data(r *http.Request, p *Page) *Data {
+	var meta map[string]string
+	if p != nil {
+		meta = p.Meta
+	}
+
+	return NewData(r, s.site, meta)
+}
+
+// Execute renders the page into its layout
+func (s *Set) Execute(w io.Writer, p *Page, data *Data) error {
+	if p.Layout == "none" {
+		return p.tmpl.Execute(w, data)
+	}
+
+	return p.tmpl.ExecuteTemplate(w, p.Layout, data)
+}
+
+// shared files are parsed into every page and are not pages themselves
+func shared(name string) bool {
+	return name == "layout.html" || strings.HasPrefix(name, "layouts/") || strings.HasPrefix(name, "partials/")
+}
+
+func (s *Set) parse() (map[string]*Page, error) {
+	base := template.New("").Funcs(s.funcs)
+
+	var names []string
+	var errs []error
+	err := fs.WalkDir(s.files, TemplatesDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || path.Ext(p) != ".html" {
+			return nil
+		}
+
+		name := strings.TrimPrefix(p, TemplatesDir+"/")
+		if !shared(name) {
+			names = append(names, name)
+			return nil
+		}
+
+		data, err := fs.ReadFile(s.files, p)
+		if err == nil {
+			_, err = base.New(name).Parse(string(data))
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	pages := make(map[string]*Page, len(names))
+	for _, name := range names {
+		p, err := s.parsePage(base, name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		pages[name] = p
+	}
+
+	return pages, errors.Join(errs...)
+}
+
+func (s *Set) parsePage(base *template.Template, name string) (*Page, error) {
+	data, err := fs.ReadFile(s.files, path.Join(TemplatesDir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	meta, body, err := frontmatter.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	t, err := base.Clone()
+	if err != nil {
+		return nil, err
+	}
+	if t, err = t.New(name).Parse(string(body)); err != nil {
+		return nil, err
+	}
+
+	p := &Page{Name: name, Meta: meta, Layout: DefaultLayout, tmpl: t}
+	if layout := meta["layout"]; layout != "" {
+		p.Layout = layout
+	}
+	if p.Layout != "none" && t.Lookup(p.Layout) == nil {
+		return nil, fmt.Errorf("%s: unknown layout %q", name, p.Layout)
+	}
+
+	return p, nil
+}
+
+// currentStamp changes when any template is added, removed or edited
+func (s *Set) currentStamp() string {
+	var buf bytes.Buffer
+	_ = fs.WalkDir(s.files, TemplatesDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			fmt.Fprintf(&buf, "%s %d %d\n", p, info.Size(), info.ModTime().UnixNano())
+		}
+		return nil
+	})
+
+	return buf.String()
+}
@@ -0,0 +1,148 @@
+package render
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ad/ton-site-ha/site"
+)
+
+func testSet(t *testing.T, files fstest.MapFS, dev bool) *Set {
+	t.Helper()
+
+	set, err := NewSet(files, Site{}, dev, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return set
+}
+
+func render(t *testing.T, set *Set, name string) string {
+	t.Helper()
+
+	page, err := set.Page(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page == nil {
+		t.Fatalf("page %s not found", name)
+	}
+
+	var sb strings.Builder
+	data := NewData(httptest.NewRequest("GET", "/"+name, nil), Site{}, page.Meta)
+	if err := set.Execute(&sb, page, data); err != nil {
+		t.Fatal(err)
+	}
+
+	return sb.String()
+}
+
+func TestSet(t *testing.T) {
+	files := fstest.MapFS{
+		"templates/layout.html":          {Data: []byte(`{{define "layout"}}[{{template "body" .}}]{{end}}`)},
+		"templates/layouts/wide.html":    {Data: []byte(`{{define "wide"}}({{template "body" .}}){{end}}`)},
+		"templates/partials/nav.html":    {Data: []byte(`nav:{{.Request.Path}}`)},
+		"templates/index.html":           {Data: []byte(`{{define "body"}}{{template "partials/nav.html" .}} <b>{{.Request.Query.Get "q"}}</b>{{end}}`)},
+		"templates/docs/guide/page.html": {Data: []byte("---\ntitle: Guide\nlayout: wide\n---\n{{define \"body\"}}{{.Title}}{{end}}")},
+		"templates/raw.html":             {Data: []byte("---\nlayout: none\n---\nraw {{.Page.layout}}")},
+	}
+	set := testSet(t, files, false)
+
+	cases := map[string]string{
+		"index.html":           "[nav:/index.html <b></b>]",
+		"docs/guide/page.html": "(Guide)",
+		"raw.html":             "raw none",
+	}
+	for name, want := range cases {
+		if got := render(t, set, name); got != want {
+			t.Errorf("%s: expected %q, but got %q", name, want, got)
+		}
+	}
+
+	for _, name := range []string{"layout.html", "partials/nav.html", "missing.html"} {
+		if p, _ := set.Page(name); p != nil {
+			t.Errorf("Expected %s not to be a page", name)
+		}
+	}
+}
+
+func TestSetEscapes(t *testing.T) {
+	files := fstest.MapFS{
+		"templates/layout.html": {Data: []byte(`{{define "layout"}}{{template "body" .}}{{end}}`)},
+		"templates/index.html":  {Data: []byte(`{{define "body"}}{{.Request.Path}}{{end}}`)},
+	}
+
+	page, _ := testSet(t, files, false).Page("index.html")
+
+	var sb strings.Builder
+	data := NewData(httptest.NewRequest("GET", "/%3Cscript%3E", nil), Site{}, nil)
+	if err := testSet(t, files, false).Execute(&sb, page, data); err != nil {
+		t.Fatal(err)
+	}
+	if sb.String() != "/&lt;script&gt;" {
+		t.Errorf("Expected escaped output, but got %q", sb.String())
+	}
+}
+
+func TestSetErrors(t *testing.T) {
+	files := fstest.MapFS{
+		"templates/layout.html": {Data: []byte(`{{define "layout"}}{{template "body" .}}{{end}}`)},
+		"templates/a.html":      {Data: []byte(`{{define "body"}}{{.Missing}{{end}}`)},
+		"templates/b.html":      {Data: []byte("---\nlayout: missing\n---\n")},
+		"templates/c.html":      {Data: []byte(`{{define "body"}}{{unknownFunc}}{{end}}`)},
+	}
+
+	_, err := NewSet(files, Site{}, false, nil)
+	if err == nil {
+		t.Fatal("Expected errors")
+	}
+	for _, name := range []string{"a.html", "b.html", "c.html"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("Expected error for %s in %s", name, err)
+		}
+	}
+}
+
+func TestSetDevMode(t *testing.T) {
+	files := fstest.MapFS{
+		"templates/layout.html": {Data: []byte(`{{define "layout"}}{{template "body" .}}{{end}}`)},
+		"templates/index.html":  {Data: []byte(`{{define "body"}}one{{end}}`)},
+	}
+	dev, prod := testSet(t, files, true), testSet(t, files, false)
+
+	files["templates/index.html"] = &fstest.MapFile{Data: []byte(`{{define "body"}}two!{{end}}`)}
+	if got := render(t, dev, "index.html"); got != "two!" {
+		t.Errorf("Expected dev mode to parse the change, but got %q", got)
+	}
+	if got := render(t, prod, "index.html"); got != "one" {
+		t.Errorf("Expected templates parsed on start, but got %q", got)
+	}
+
+	files["templates/index.html"] = &fstest.MapFile{Data: []byte(`{{define "body"}}{{end`)}
+	if _, err := dev.Page("index.html"); err == nil {
+		t.Error("Expected error for a broken template in dev mode")
+	}
+}
+
+func TestEmbeddedSite(t *testing.T) {
+	set, err := NewSet(site.Templates, Site{}, false, map[string]any{"tagURL": func(string) string { return "" }})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(render(t, set, "index.html"), "<html") {
+		t.Error("Expected index.html to render into the layout")
+	}
+
+	for _, code := range []int{404, 500, 503} {
+		body, err := set.renderError(httptest.NewRequest("GET", "/", nil), code, "error")
+		if err != nil {
+			t.Errorf("%d: %s", code, err)
+		} else if !strings.Contains(string(body), "<html") {
+			t.Errorf("Expected the %d page to render into the layout", code)
+		}
+	}
+}
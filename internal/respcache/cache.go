@@ -0,0 +1,377 @@
+// Package respcache keeps rendered and compressed responses in memory, so hot pages are not
+// rendered and compressed again for every visitor
+package respcache
+
+import (
+	"bytes"
+	"container/list"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ad/ton-site-ha/internal/compress"
+)
+
+// DefaultTTL is how long responses are kept, shorter with a smaller max-age or s-maxage. The
+// cache is part of the site, so it may keep what browsers must check, like pages with no-cache,
+// but not longer than this: edits in the content directory show up after DefaultTTL, or right
+// away on reload, whatever max-age tells browsers.
+const DefaultTTL = 10 * time.Second
+
+// entry is a stored response
+type entry struct {
+	key     string
+	code    int
+	header  http.Header
+	body    []byte
+	expires time.Time
+	size    int64
+}
+
+// Stats are the counters reported as metrics
+type Stats struct {
+	Hits, Misses uint64
+	Entries      int
+	Size, Limit  int64
+}
+
+// HitRatio is the share of lookups answered from the cache
+func (s Stats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// Cache is an LRU of responses bounded by the size of their bodies and headers
+type Cache struct {
+	mx      sync.Mutex
+	limit   int64
+	size    int64
+	lru     *list.List
+	entries map[string]*list.Element
+
+	hits, misses atomic.Uint64
+}
+
+// New creates a cache of limit bytes, 0 disables it
+func New(limit int64) *Cache {
+	return &Cache{limit: limit, lru: list.New(), entries: map[string]*list.Element{}}
+}
+
+// SetLimit changes the size of the cache, dropping the least recently used responses
+// that do not fit anymore
+func (c *Cache) SetLimit(limit int64) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.limit = limit
+	c.evict()
+}
+
+// Purge drops every response, the site changed
+func (c *Cache) Purge() {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.lru.Init()
+	clear(c.entries)
+	c.size = 0
+}
+
+// Stats returns the current counters
+func (c *Cache) Stats() Stats {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	return Stats{Hits: c.hits.Load(), Misses: c.misses.Load(), Entries: c.lru.Len(), Size: c.size, Limit: c.limit}
+}
+
+func (c *Cache) get(key string) *entry {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	el := c.entries[key]
+	if el == nil {
+		return nil
+	}
+
+	e := el.Value.(*entry)
+	if time.Now().After(e.expires) {
+		c.remove(el)
+		return nil
+	}
+	c.lru.MoveToFront(el)
+
+	return e
+}
+
+func (c *Cache) put(e *entry) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if el := c.entries[e.key]; el != nil {
+		c.remove(el)
+	}
+	if e.size > c.maxEntry() {
+		return
+	}
+
+	c.entries[e.key] = c.lru.PushFront(e)
+	c.size += e.size
+	c.evict()
+}
+
+// maxEntry keeps a single large response from pushing out everything else
+func (c *Cache) maxEntry() int64 {
+	return c.limit / 4
+}
+
+func (c *Cache) evict() {
+	for c.size > c.limit && c.lru.Len() > 0 {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *Cache) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*entry)
+	delete(c.entries, e.key)
+	c.size -= e.size
+}
+
+// Handler answers from the cache and stores the responses of next that may be stored.
+// The key is the method, host, URL and the encoding next would compress with, so it belongs
+// before compress.Handler. Range and conditional requests other than If-None-Match are
+// passed through, If-None-Match matching a stored ETag gets 304.
+func (c *Cache) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !c.cacheable(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := r.Method + " " + r.Host + r.URL.RequestURI() + " " + compress.Negotiate(r.Header.Get("Accept-Encoding"))
+		if !requestNoCache(r.Header) {
+			if e := c.get(key); e != nil {
+				c.hits.Add(1)
+				e.serve(w, r)
+				return
+			}
+		}
+		c.misses.Add(1)
+
+		rec := &recorder{ResponseWriter: w, code: http.StatusOK, max: c.maxEntry()}
+		next.ServeHTTP(rec, r)
+
+		if ttl, ok := storable(rec.code, w.Header()); ok && !rec.tooBig && !rec.noStore && !requestNoStore(r.Header) {
+			header := w.Header().Clone()
+			c.put(&entry{
+				key:     key,
+				code:    rec.code,
+				header:  header,
+				body:    rec.buf.Bytes(),
+				expires: time.Now().Add(ttl),
+				size:    int64(len(key)+rec.buf.Len()) + headerSize(header),
+			})
+		}
+	})
+}
+
+func (c *Cache) cacheable(r *http.Request) bool {
+	c.mx.Lock()
+	limit := c.limit
+	c.mx.Unlock()
+
+	if limit <= 0 || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return false
+	}
+
+	h := r.Header
+	return h.Get("Range") == "" && h.Get("If-Range") == "" && h.Get("If-Modified-Since") == "" &&
+		h.Get("If-Match") == "" && h.Get("If-Unmodified-Since") == "" && h.Get("Authorization") == ""
+}
+
+func (e *entry) serve(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	for k, v := range e.header {
+		h[k] = append([]string(nil), v...)
+	}
+
+	if e.code == http.StatusOK && etagMatch(r.Header.Get("If-None-Match"), e.header.Get("ETag")) {
+		for _, k := range []string{"Content-Type", "Content-Length", "Content-Encoding"} {
+			h.Del(k)
+		}
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.WriteHeader(e.code)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(e.body)
+	}
+}
+
+// etagMatch is the weak comparison of If-None-Match
+func etagMatch(header, etag string) bool {
+	if header == "" || etag == "" {
+		return false
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// storable reports if the response may be stored and for how long: not with no-store,
+// private, cookies or a Vary on anything but Accept-Encoding, which is part of the key
+func storable(code int, h http.Header) (time.Duration, bool) {
+	switch code {
+	case http.StatusOK, http.StatusMovedPermanently, http.StatusPermanentRedirect, http.StatusNotFound, http.StatusGone:
+	default:
+		return 0, false
+	}
+	if h.Get("Set-Cookie") != "" || h.Get("Content-Range") != "" {
+		return 0, false
+	}
+
+	for _, v := range h.Values("Vary") {
+		for _, field := range strings.Split(v, ",") {
+			if field = strings.TrimSpace(field); field != "" && !strings.EqualFold(field, "Accept-Encoding") {
+				return 0, false
+			}
+		}
+	}
+
+	ttl, maxAge, sMaxAge := DefaultTTL, -1, -1
+	for _, directive := range strings.Split(strings.Join(h.Values("Cache-Control"), ","), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-store", "private":
+			return 0, false
+		case "max-age":
+			maxAge, _ = strconv.Atoi(strings.Trim(value, `"`))
+		case "s-maxage":
+			sMaxAge, _ = strconv.Atoi(strings.Trim(value, `"`))
+		}
+	}
+
+	switch {
+	case sMaxAge >= 0:
+		ttl = min(ttl, time.Duration(sMaxAge)*time.Second)
+	case maxAge > 0:
+		ttl = min(ttl, time.Duration(maxAge)*time.Second)
+	}
+
+	return ttl, ttl > 0
+}
+
+// SharedOnly keeps the responses of next out of the cache unless they are explicitly
+// meant for shared caches with public or s-maxage. Proxied upstreams answer visitors by
+// their forwarded identity, which is not part of the key.
+func SharedOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+
+		if !shared(w.Header()) {
+			NoStore(w)
+		}
+	})
+}
+
+// NoStore keeps the response written to w out of the cache of the handler chain
+func NoStore(w http.ResponseWriter) {
+	for w != nil {
+		if rec, ok := w.(*recorder); ok {
+			rec.noStore = true
+			return
+		}
+
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return
+		}
+		w = u.Unwrap()
+	}
+}
+
+// shared reports if Cache-Control lets shared caches store the response
+func shared(h http.Header) bool {
+	for _, directive := range strings.Split(strings.Join(h.Values("Cache-Control"), ","), ",") {
+		name, _, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if strings.EqualFold(name, "public") || strings.EqualFold(name, "s-maxage") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func requestNoCache(h http.Header) bool {
+	cc := strings.ToLower(h.Get("Cache-Control"))
+	return strings.Contains(cc, "no-cache") || strings.Contains(cc, "no-store") || strings.Contains(cc, "max-age=0") ||
+		strings.EqualFold(h.Get("Pragma"), "no-cache")
+}
+
+func requestNoStore(h http.Header) bool {
+	return strings.Contains(strings.ToLower(h.Get("Cache-Control")), "no-store")
+}
+
+func headerSize(h http.Header) int64 {
+	var n int64
+	for k, v := range h {
+		n += int64(len(k))
+		for _, s := range v {
+			n += int64(len(s))
+		}
+	}
+
+	return n
+}
+
+// recorder passes the response through and keeps a copy of it, up to max bytes
+type recorder struct {
+	http.ResponseWriter
+	code        int
+	wroteHeader bool
+
+	buf     bytes.Buffer
+	max     int64
+	tooBig  bool
+	noStore bool
+}
+
+func (r *recorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.code, r.wroteHeader = code, true
+	}
+
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	if !r.tooBig {
+		if int64(r.buf.Len()+len(p)) > r.max {
+			r.tooBig = true
+			r.buf = bytes.Buffer{}
+		} else {
+			r.buf.Write(p)
+		}
+	}
+
+	return r.ResponseWriter.Write(p)
+}
+
+func (r *recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
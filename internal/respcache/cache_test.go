@@ -0,0 +1,188 @@
+package respcache
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// counter is a handler counting how often it renders
+type counter struct {
+	calls  int
+	header http.Header
+	body   string
+}
+
+func (c *counter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.calls++
+	for k, v := range c.header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("ETag", `"v1"`)
+	fmt.Fprintf(w, "%s %s", c.body, r.URL.Path)
+}
+
+func get(h http.Handler, path string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestCache(t *testing.T) {
+	next := &counter{body: "page"}
+	c := New(1 << 20)
+	h := c.Handler(next)
+
+	for range 3 {
+		if w := get(h, "/a"); w.Body.String() != "page /a" || w.Header().Get("ETag") != `"v1"` {
+			t.Fatalf("Unexpected response %q %v", w.Body.String(), w.Header())
+		}
+	}
+	if next.calls != 1 {
+		t.Errorf("Expected the page rendered once, but got %d", next.calls)
+	}
+
+	// the encoding is part of the key
+	get(h, "/a", "Accept-Encoding", "gzip")
+	get(h, "/a", "Accept-Encoding", "gzip, br")
+	if next.calls != 2 {
+		t.Errorf("Expected one render per encoding, but got %d", next.calls)
+	}
+
+	if w := get(h, "/a", "If-None-Match", `W/"v1"`); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("Expected 304 from the cache, but got %d", w.Code)
+	}
+
+	get(h, "/a", "Cache-Control", "no-cache")
+	get(h, "/a", "Range", "bytes=0-1")
+	if next.calls != 4 {
+		t.Errorf("Expected no-cache and range requests to be rendered, but got %d renders", next.calls)
+	}
+
+	st := c.Stats()
+	if st.Entries != 2 || st.Hits != 4 || st.Misses != 3 || st.Size <= 0 {
+		t.Errorf("Unexpected stats %+v", st)
+	}
+
+	c.Purge()
+	get(h, "/a")
+	if next.calls != 5 || c.Stats().Entries != 1 {
+		t.Errorf("Expected a render after purge, but got %d", next.calls)
+	}
+}
+
+func TestCacheNotStored(t *testing.T) {
+	cases := map[string]http.Header{
+		"private":  {"Cache-Control": {"private"}},
+		"no-store": {"Cache-Control": {"no-store"}},
+		"max-age":  {"Cache-Control": {"public, s-maxage=0"}},
+		"vary":     {"Vary": {"Accept-Encoding, Cookie"}},
+		"cookie":   {"Set-Cookie": {"a=b"}},
+	}
+
+	for name, header := range cases {
+		next := &counter{header: header}
+		h := New(1 << 20).Handler(next)
+		get(h, "/")
+		get(h, "/")
+		if next.calls != 2 {
+			t.Errorf("%s: expected the response not to be stored", name)
+		}
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	next := &counter{header: http.Header{"Cache-Control": {"max-age=60"}}}
+	c := New(1 << 20)
+	h := c.Handler(next)
+	get(h, "/")
+
+	c.entries[c.lru.Front().Value.(*entry).key].Value.(*entry).expires = time.Now().Add(-time.Second)
+	get(h, "/")
+	if next.calls != 2 {
+		t.Errorf("Expected an expired response to be rendered again, but got %d", next.calls)
+	}
+}
+
+func TestCacheTTLCapped(t *testing.T) {
+	cases := map[string]time.Duration{
+		"public, max-age=86400":  DefaultTTL,
+		"s-maxage=3600":          DefaultTTL,
+		"no-cache":               DefaultTTL,
+		"max-age=5":              5 * time.Second,
+		"max-age=60, s-maxage=2": 2 * time.Second,
+	}
+
+	for cc, want := range cases {
+		if ttl, ok := storable(http.StatusOK, http.Header{"Cache-Control": {cc}}); !ok || ttl != want {
+			t.Errorf("%s: expected %s, but got %s %v", cc, want, ttl, ok)
+		}
+	}
+}
+
+func TestSharedOnly(t *testing.T) {
+	cases := map[string]bool{
+		"":                   false,
+		"max-age=60":         false,
+		"no-cache":           false,
+		"public, max-age=60": true,
+		"s-maxage=60":        true,
+	}
+
+	for cc, stored := range cases {
+		next := &counter{header: http.Header{}}
+		if cc != "" {
+			next.header.Set("Cache-Control", cc)
+		}
+		h := New(1 << 20).Handler(SharedOnly(next))
+		get(h, "/")
+		get(h, "/")
+		if got := next.calls == 1; got != stored {
+			t.Errorf("%q: expected stored %v, but got %d calls", cc, stored, next.calls)
+		}
+	}
+}
+
+func TestCacheLimit(t *testing.T) {
+	next := &counter{body: strings.Repeat("x", 1000)}
+	c := New(10000)
+	h := c.Handler(next)
+
+	for i := range 20 {
+		get(h, fmt.Sprintf("/%d", i))
+	}
+	if st := c.Stats(); st.Size > 10000 || st.Entries == 0 || st.Entries >= 20 {
+		t.Errorf("Expected the cache bounded by its limit, but got %+v", st)
+	}
+
+	// the most recent one is kept, the first one was evicted
+	calls := next.calls
+	get(h, "/19")
+	get(h, "/0")
+	if next.calls != calls+1 {
+		t.Errorf("Expected LRU eviction, but got %d renders", next.calls-calls)
+	}
+
+	// larger than a quarter of the cache, passed through without storing
+	next.body = strings.Repeat("x", 5000)
+	if w := get(h, "/big"); w.Body.Len() < 5000 {
+		t.Error("Expected the whole response")
+	}
+	if _, ok := c.entries["GET example.com/big "]; ok {
+		t.Error("Expected the large response not to be stored")
+	}
+
+	c.SetLimit(0)
+	calls = next.calls
+	get(h, "/19")
+	if next.calls != calls+1 || c.Stats().Entries != 0 {
+		t.Error("Expected a disabled cache to be empty and pass requests through")
+	}
+}
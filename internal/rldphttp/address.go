@@ -1,21 +1,103 @@
 package rldphttp
 
 import (
+	"crypto/ed25519"
 	"encoding/base32"
+	"encoding/base64"
 	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"github.com/sigurn/crc16"
 	"strings"
+
+	"github.com/sigurn/crc16"
+	"github.com/xssnick/tonutils-go/adnl/keys"
+	"github.com/xssnick/tonutils-go/tl"
 )
 
 var crc16table = crc16.MakeTable(crc16.CRC16_XMODEM)
 
+// ADNLAddress is the 32 byte ADNL id of a site. As text it is the 55 character base32
+// form used in .adnl domains, when parsed hex, base64, uppercase and the .adnl suffix
+// are accepted as well.
+type ADNLAddress [32]byte
+
+// ADNLAddressFromPublicKey derives the address of a server with the given key
+func ADNLAddressFromPublicKey(key ed25519.PublicKey) (ADNLAddress, error) {
+	var a ADNLAddress
+	if len(key) != ed25519.PublicKeySize {
+		return a, errors.New("wrong public key length")
+	}
+
+	id, err := tl.Hash(keys.PublicKeyED25519{Key: key})
+	if err != nil {
+		return a, err
+	}
+	copy(a[:], id)
+
+	return a, nil
+}
+
+// String returns the base32 form without the .adnl suffix
+func (a ADNLAddress) String() string {
+	s, _ := SerializeADNLAddress(a[:])
+	return s
+}
+
+// Hex returns the ADNL id in hex, as ADNL and DHT tools print it
+func (a ADNLAddress) Hex() string {
+	return hex.EncodeToString(a[:])
+}
+
+func (a ADNLAddress) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a *ADNLAddress) UnmarshalText(text []byte) error {
+	id, err := ParseADNLAddress(string(text))
+	if err != nil {
+		return err
+	}
+	copy(a[:], id)
+
+	return nil
+}
+
+// Set implements flag.Value
+func (a *ADNLAddress) Set(s string) error {
+	return a.UnmarshalText([]byte(s))
+}
+
+// ParseADNLAddress returns the ADNL id from the base32 address, optionally with the
+// .adnl suffix and in any case, or from the id in hex or base64
 func ParseADNLAddress(addr string) ([]byte, error) {
-	if len(addr) != 55 {
-		return nil, errors.New("wrong id length")
+	addr = strings.TrimSpace(addr)
+	if len(addr) > 5 && strings.EqualFold(addr[len(addr)-5:], ".adnl") {
+		addr = addr[:len(addr)-5]
 	}
 
+	switch len(addr) {
+	case 55:
+		return parseBase32ADNLAddress(addr)
+	case 64:
+		id, err := hex.DecodeString(addr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode hex address: %w", err)
+		}
+		return id, nil
+	case 43, 44:
+		for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+			if id, err := enc.Strict().DecodeString(addr); err == nil && len(id) == 32 {
+				return id, nil
+			}
+		}
+		return nil, errors.New("failed to decode base64 address")
+	}
+
+	return nil, errors.New("wrong id length")
+}
+
+func parseBase32ADNLAddress(addr string) ([]byte, error) {
 	buf, err := base32.StdEncoding.DecodeString("F" + strings.ToUpper(addr))
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode address: %w", err)
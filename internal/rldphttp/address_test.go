@@ -0,0 +1,175 @@
+package rldphttp
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"strings"
+	"testing"
+)
+
+const (
+	testAddress = "xfyrc2stvonvmxopkqcuqjge242ewkayeccebwnr3uz3oznjia7qjao"
+	testID      = "cb888b529d5cdab2ee7aa02a412626b9a25940c1042206cd8ee99dbb2d4a01f8"
+)
+
+func TestADNLAddressFromPublicKey(t *testing.T) {
+	key := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, ed25519.SeedSize))
+
+	a, err := ADNLAddressFromPublicKey(key.Public().(ed25519.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.String() != testAddress || a.Hex() != testID {
+		t.Errorf("Expected %s (%s), but got %s (%s)", testAddress, testID, a, a.Hex())
+	}
+
+	if _, err := ADNLAddressFromPublicKey(key.Public().(ed25519.PublicKey)[:31]); err == nil {
+		t.Error("Expected error for a short key")
+	}
+}
+
+func TestParseADNLAddressForms(t *testing.T) {
+	id, _ := hex.DecodeString(testID)
+
+	for _, s := range []string{
+		testAddress,
+		testAddress + ".adnl",
+		strings.ToUpper(testAddress) + ".ADNL",
+		" " + testAddress + "\n",
+		testID,
+		strings.ToUpper(testID),
+		base64.StdEncoding.EncodeToString(id),
+		base64.RawURLEncoding.EncodeToString(id),
+	} {
+		got, err := ParseADNLAddress(s)
+		if err != nil {
+			t.Errorf("Expected %q to parse, but got %s", s, err)
+			continue
+		}
+		if !bytes.Equal(got, id) {
+			t.Errorf("Expected %q to be %s, but got %x", s, testID, got)
+		}
+	}
+
+	for _, s := range []string{"", ".adnl", testAddress[:54], "a" + testAddress[1:], testID[:63] + "g"} {
+		if _, err := ParseADNLAddress(s); err == nil {
+			t.Errorf("Expected error for %q", s)
+		}
+	}
+}
+
+func TestADNLAddressFlagAndJSON(t *testing.T) {
+	var a ADNLAddress
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.Var(&a, "address", "site address")
+	if err := flags.Parse([]string{"-address", testAddress + ".adnl"}); err != nil {
+		t.Fatal(err)
+	}
+	if a.Hex() != testID {
+		t.Errorf("Expected %s, but got %s", testID, a.Hex())
+	}
+
+	data, err := json.Marshal(map[string]ADNLAddress{"address": a})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"address":"`+testAddress+`"}` {
+		t.Errorf("Unexpected JSON %s", data)
+	}
+
+	var decoded map[string]ADNLAddress
+	if err := json.Unmarshal([]byte(`{"address":"`+testID+`"}`), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded["address"] != a {
+		t.Errorf("Expected %s from JSON, but got %s", a, decoded["address"])
+	}
+}
+
+func FuzzADNLAddressRoundTrip(f *testing.F) {
+	id, _ := hex.DecodeString(testID)
+	f.Add(id)
+	f.Add(make([]byte, 32))
+	f.Add(bytes.Repeat([]byte{0xff}, 32))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if len(data) != 32 {
+			return
+		}
+
+		var a ADNLAddress
+		copy(a[:], data)
+
+		forms := []string{
+			a.String(),
+			a.String() + ".adnl",
+			strings.ToUpper(a.String()),
+			a.Hex(),
+			base64.StdEncoding.EncodeToString(a[:]),
+			base64.RawStdEncoding.EncodeToString(a[:]),
+			base64.URLEncoding.EncodeToString(a[:]),
+			base64.RawURLEncoding.EncodeToString(a[:]),
+		}
+		for _, s := range forms {
+			var b ADNLAddress
+			if err := b.Set(s); err != nil {
+				t.Fatalf("failed to parse %q: %s", s, err)
+			}
+			if b != a {
+				t.Fatalf("%q parsed to %s, expected %s", s, b.Hex(), a.Hex())
+			}
+		}
+
+		if len(a.String()) != 55 || !strings.ContainsRune("uvwx", rune(a.String()[0])) {
+			t.Fatalf("unexpected address form %q", a)
+		}
+
+		text, err := a.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b ADNLAddress
+		if err := b.UnmarshalText(text); err != nil || b != a {
+			t.Fatalf("text round trip of %s failed: %v", a.Hex(), err)
+		}
+
+		data, err = json.Marshal(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b = ADNLAddress{}
+		if err := json.Unmarshal(data, &b); err != nil || b != a {
+			t.Fatalf("JSON round trip of %s failed: %v", a.Hex(), err)
+		}
+	})
+}
+
+func FuzzParseADNLAddress(f *testing.F) {
+	f.Add(testAddress)
+	f.Add(testAddress + ".adnl")
+	f.Add(testID)
+	f.Add("")
+
+	f.Fuzz(func(t *testing.T, s string) {
+		id, err := ParseADNLAddress(s)
+		if err != nil {
+			return
+		}
+		if len(id) != 32 {
+			t.Fatalf("%q parsed to %d bytes", s, len(id))
+		}
+
+		// whatever was accepted is the same address in the canonical form
+		var a ADNLAddress
+		copy(a[:], id)
+		again, err := ParseADNLAddress(a.String())
+		if err != nil || !bytes.Equal(again, id) {
+			t.Fatalf("canonical form of %q does not parse back: %v", s, err)
+		}
+	})
+}
@@ -0,0 +1,103 @@
+package rldphttp
+
+import (
+	"crypto/ed25519"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/xssnick/tonutils-go/adnl"
+)
+
+// identity is an ADNL address the server answers on, all of them share one UDP socket
+type identity struct {
+	id       []byte
+	key      ed25519.PrivateKey
+	handler  http.Handler
+	gateway  ADNLGateway
+	retireAt time.Time
+	retired  bool
+}
+
+func (s *Server) newIdentity(key ed25519.PrivateKey, handler http.Handler) *identity {
+	id, _ := ADNLAddressFromPublicKey(key.Public().(ed25519.PublicKey))
+	return &identity{
+		id:      id[:],
+		key:     key,
+		handler: handler,
+		gateway: newServer(key, s.net),
+	}
+}
+
+// AddIdentity makes the server answer on one more ADNL address with its own handler,
+// it is used to keep the old address alive during key rotation.
+// The address stops being announced in DHT after retireAt, zero value means never.
+// Must be called before ListenAndServe.
+func (s *Server) AddIdentity(key ed25519.PrivateKey, handler http.Handler, retireAt time.Time) []byte {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	ident := s.newIdentity(key, handler)
+	ident.retireAt = retireAt
+	s.identities = append(s.identities, ident)
+
+	return ident.id
+}
+
+// sharedNet opens the UDP socket on first use and routes packets between the gateways of all identities
+type sharedNet struct {
+	multi *adnl.MultiNetManager
+	mx    sync.Mutex
+}
+
+func (n *sharedNet) InitConnection(gate *adnl.Gateway, addr string) error {
+	n.mx.Lock()
+	if n.multi == nil {
+		conn, err := adnl.DefaultListener(addr)
+		if err != nil {
+			n.mx.Unlock()
+			return err
+		}
+		n.multi = adnl.NewMultiNetReader(conn)
+	}
+	n.mx.Unlock()
+
+	return n.multi.InitConnection(gate, addr)
+}
+
+func (n *sharedNet) manager() *adnl.MultiNetManager {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+
+	return n.multi
+}
+
+func (n *sharedNet) Free(p *adnl.UDPPacket) {
+	n.manager().Free(p)
+}
+
+func (n *sharedNet) GetReaderChan(gate *adnl.Gateway) <-chan *adnl.UDPPacket {
+	return n.manager().GetReaderChan(gate)
+}
+
+func (n *sharedNet) CloseConnection(gate *adnl.Gateway) {
+	if m := n.manager(); m != nil {
+		m.CloseConnection(gate)
+	}
+}
+
+func (n *sharedNet) WritePacket(gate *adnl.Gateway, p []byte, addr net.Addr) (int, error) {
+	m := n.manager()
+	if m == nil {
+		return 0, io.ErrClosedPipe
+	}
+	return m.WritePacket(gate, p, addr)
+}
+
+func (n *sharedNet) Close() {
+	if m := n.manager(); m != nil {
+		m.Close()
+	}
+}
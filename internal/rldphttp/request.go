@@ -0,0 +1,19 @@
+package rldphttp
+
+import (
+	"context"
+	"encoding/hex"
+)
+
+type requestIDKey struct{}
+
+// RequestID returns the hex id of the RLDP request being served, empty for requests not from RLDP
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithRequestID returns ctx carrying the RLDP request id
+func WithRequestID(ctx context.Context, id []byte) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, hex.EncodeToString(id))
+}
@@ -19,13 +19,13 @@ import (
 
 	"github.com/xssnick/tonutils-go/adnl"
 	"github.com/xssnick/tonutils-go/adnl/address"
-	"github.com/xssnick/tonutils-go/adnl/keys"
 	"github.com/xssnick/tonutils-go/adnl/rldp"
-	"github.com/xssnick/tonutils-go/tl"
 )
 
 type ADNLGateway interface {
+	GetID() []byte
 	GetAddressList() address.List
+	RegisterClient(addr string, key ed25519.PublicKey) (adnl.Peer, error)
 	Close() error
 	SetConnectionHandler(func(client adnl.Peer) error)
 	SetAddressList(addresses []*address.UDP)
@@ -35,12 +35,10 @@ type ADNLGateway interface {
 type Server struct {
 	dht DHT
 
-	id             []byte
-	key            ed25519.PrivateKey
-	handler        http.Handler
+	identities     []*identity
+	net            *sharedNet
 	rldpInfos      map[string]*rldpInfo
 	activeRequests map[string]*payloadStream
-	adnlServer     ADNLGateway
 	externalIp     net.IP
 
 	closer chan bool
@@ -60,6 +58,8 @@ type writerBuff struct {
 
 	headerSent bool
 	handled    bool
+	// head responses keep the Content-Length of the body they leave out
+	head bool
 
 	maxAnswerSz uint64
 	timeoutAt   uint32
@@ -78,25 +78,35 @@ type respWriter struct {
 
 var Logger = log.Println
 
-var newServer = func(key ed25519.PrivateKey) ADNLGateway {
-	return adnl.NewGateway(key)
+var newServer = func(key ed25519.PrivateKey, net adnl.NetManager) ADNLGateway {
+	return adnl.NewGatewayWithNetManager(key, net)
 }
 
 func NewServer(key ed25519.PrivateKey, dht DHT, handler http.Handler) *Server {
 	s := &Server{
-		key:            key,
 		dht:            dht,
-		handler:        handler,
+		net:            &sharedNet{},
 		rldpInfos:      map[string]*rldpInfo{},
 		activeRequests: map[string]*payloadStream{},
 		closer:         make(chan bool, 1),
 		Timeout:        30 * time.Second,
-		adnlServer:     newServer(key),
 	}
-	s.id, _ = tl.Hash(keys.PublicKeyED25519{Key: s.key.Public().(ed25519.PublicKey)})
+	s.identities = []*identity{s.newIdentity(key, handler)}
 	return s
 }
 
+// SetDHT sets the DHT client used to announce the server addresses,
+// it allows to create the client on top of the server's own Gateway
+func (s *Server) SetDHT(dht DHT) {
+	s.dht = dht
+}
+
+// Gateway returns the ADNL gateway of the main identity,
+// it can be used by a DHT client to share the server's UDP port
+func (s *Server) Gateway() ADNLGateway {
+	return s.identities[0].gateway
+}
+
 func (s *Server) SetExternalIP(ip net.IP) {
 	s.externalIp = ip
 }
@@ -133,22 +143,35 @@ func (s *Server) ListenAndServe(listenAddr string) error {
 			case <-time.After(wait):
 			}
 
-			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-			err := s.updateDHT(ctx)
-			cancel()
+			wait = 1 * time.Minute
+			for _, ident := range s.activeIdentities() {
+				ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+				err := s.updateDHT(ctx, ident)
+				cancel()
 
-			if err != nil {
-				Logger("DHT ADNL address record update failed: ", err, ". We will retry in 5 sec")
+				if err != nil {
+					Logger("DHT ADNL address record update failed: ", err, ". We will retry in 5 sec")
 
-				// on err, retry sooner
-				wait = 5 * time.Second
-				continue
+					// on err, retry sooner
+					wait = 5 * time.Second
+				}
 			}
-			wait = 1 * time.Minute
 		}
 	}()
 
-	s.adnlServer.SetConnectionHandler(func(client adnl.Peer) error {
+	for _, ident := range s.identities {
+		if err := s.startIdentity(ident, listenAddr); err != nil {
+			_ = s.Stop()
+			return err
+		}
+	}
+
+	<-s.closer
+	return nil
+}
+
+func (s *Server) startIdentity(ident *identity, listenAddr string) error {
+	ident.gateway.SetConnectionHandler(func(client adnl.Peer) error {
 		adnlAddr, err := SerializeADNLAddress(client.GetID())
 		if err != nil {
 			return err
@@ -173,7 +196,7 @@ func (s *Server) ListenAndServe(listenAddr string) error {
 		})
 
 		rl := newRLDP(client, false) // server supports both v2 and v1 by default
-		rl.SetOnQuery(s.handle(rl, adnlAddr, client.RemoteAddr()))
+		rl.SetOnQuery(s.handle(rl, ident.handler, adnlAddr, client.RemoteAddr()))
 		return nil
 	})
 
@@ -182,30 +205,44 @@ func (s *Server) ListenAndServe(listenAddr string) error {
 		if err == nil {
 			port, err := strconv.ParseInt(portStr, 10, 32)
 			if err == nil {
-				s.adnlServer.SetAddressList([]*address.UDP{{IP: s.externalIp, Port: int32(port)}})
+				ident.gateway.SetAddressList([]*address.UDP{{IP: s.externalIp, Port: int32(port)}})
 			}
 		}
 	}
 
-	err := s.adnlServer.StartServer(listenAddr)
-	if err != nil {
-		_ = s.Stop()
-		return err
-	}
+	return ident.gateway.StartServer(listenAddr)
+}
 
-	<-s.closer
-	return nil
+// activeIdentities returns identities which are still announced in DHT
+func (s *Server) activeIdentities() []*identity {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	now := time.Now()
+	var list []*identity
+	for _, ident := range s.identities {
+		if !ident.retireAt.IsZero() && now.After(ident.retireAt) {
+			if !ident.retired {
+				ident.retired = true
+				addr, _ := SerializeADNLAddress(ident.id)
+				Logger("ADNL address", addr+".adnl", "is retired and no longer announced in DHT")
+			}
+			continue
+		}
+		list = append(list, ident)
+	}
+	return list
 }
 
 func (s *Server) Address() []byte {
-	return s.id
+	return s.identities[0].id
 }
 
-func (s *Server) updateDHT(ctx context.Context) error {
-	addr := s.adnlServer.GetAddressList()
+func (s *Server) updateDHT(ctx context.Context, ident *identity) error {
+	addr := ident.gateway.GetAddressList()
 
 	ctxStore, cancel := context.WithTimeout(ctx, 80*time.Second)
-	stored, id, err := s.dht.StoreAddress(ctxStore, addr, 15*time.Minute, s.key, 5)
+	stored, id, err := s.dht.StoreAddress(ctxStore, addr, 15*time.Minute, ident.key, 5)
 	cancel()
 	if err != nil && stored == 0 {
 		return err
@@ -229,16 +266,20 @@ func (s *Server) Stop() (err error) {
 	if !s.closed {
 		s.closed = true
 		close(s.closer)
-		s.dht.Close()
+		if s.dht != nil {
+			s.dht.Close()
+		}
 
-		if s.adnlServer != nil {
-			err = s.adnlServer.Close()
+		for _, ident := range s.identities {
+			if closeErr := ident.gateway.Close(); closeErr != nil {
+				err = closeErr
+			}
 		}
 	}
 	return
 }
 
-func (s *Server) handle(client RLDP, adnlId, addr string) func(transferId []byte, msg *rldp.Query) error {
+func (s *Server) handle(client RLDP, handler http.Handler, adnlId, addr string) func(transferId []byte, msg *rldp.Query) error {
 	netAddr := net.UDPAddrFromAddrPort(netip.MustParseAddrPort(addr))
 
 	return func(transferId []byte, query *rldp.Query) error {
@@ -305,6 +346,7 @@ func (s *Server) handle(client RLDP, adnlId, addr string) func(transferId []byte
 				RemoteAddr:    netAddr.IP.String(),
 				RequestURI:    uri.RequestURI(),
 			}
+			httpReq = httpReq.WithContext(WithRequestID(ctx, req.ID))
 
 			stream := newDataStreamer()
 
@@ -317,6 +359,7 @@ func (s *Server) handle(client RLDP, adnlId, addr string) func(transferId []byte
 				queryId:     query.ID,
 				requestId:   req.ID,
 				transferId:  transferId,
+				head:        req.Method == http.MethodHead,
 			}
 
 			w := &respWriter{
@@ -325,7 +368,7 @@ func (s *Server) handle(client RLDP, adnlId, addr string) func(transferId []byte
 			}
 			wb.resp = w
 
-			s.handler.ServeHTTP(w, httpReq)
+			handler.ServeHTTP(w, httpReq)
 			wb.handled = true
 			// flush write buffer, to commit data
 			err = w.writer.Flush()
@@ -337,9 +380,13 @@ func (s *Server) handle(client RLDP, adnlId, addr string) func(transferId []byte
 			}
 			stream.Finish()
 		case GetNextPayloadPart:
-			s.mx.RLock()
+			s.mx.Lock()
 			stream := s.activeRequests[hex.EncodeToString(req.ID)]
-			s.mx.RUnlock()
+			if stream != nil {
+				// large downloads take longer than Timeout, the stream expires when the client stops asking
+				stream.ValidTill = time.Now().Add(s.Timeout)
+			}
+			s.mx.Unlock()
 
 			if stream == nil {
 				return fmt.Errorf("unknown request id %s", hex.EncodeToString(req.ID))
@@ -428,7 +475,8 @@ func (w *writerBuff) flush(payload []byte) error {
 
 	if w.handled {
 		// if it is first and last write - we can define content length
-		if !strings.Contains(strings.ToLower(w.resp.headers.Get("Transfer-Encoding")), "chunked") {
+		if !strings.Contains(strings.ToLower(w.resp.headers.Get("Transfer-Encoding")), "chunked") &&
+			(!w.head || w.resp.headers.Get("Content-Length") == "") {
 			w.resp.headers.Set("Content-Length", fmt.Sprint(len(payload)))
 		}
 	} else {
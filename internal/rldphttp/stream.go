@@ -11,6 +11,10 @@ type payloadStream struct {
 	Data       io.ReadCloser
 	ValidTill  time.Time
 
+	// lastPart is sent again if the client asks for lastOffset twice
+	lastOffset int
+	lastPart   *PayloadPart
+
 	mx sync.Mutex
 }
 
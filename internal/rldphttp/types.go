@@ -52,7 +52,12 @@ func handleGetPart(req GetNextPayloadPart, stream *payloadStream) (*PayloadPart,
 	stream.mx.Lock()
 	defer stream.mx.Unlock()
 
-	offset := int(req.Seqno * req.MaxChunkSize)
+	// int, not int32, so offsets past 2 GiB of large files do not overflow
+	offset := int(req.Seqno) * int(req.MaxChunkSize)
+	if stream.lastPart != nil && offset == stream.lastOffset {
+		// the answer got lost and the part is asked for again, the stream can't go back
+		return stream.lastPart, nil
+	}
 	if offset != stream.nextOffset {
 		return nil, fmt.Errorf("failed to get part for stream %s, incorrect offset %d, should be %d", hex.EncodeToString(req.ID), offset, stream.nextOffset)
 	}
@@ -68,9 +73,12 @@ func handleGetPart(req GetNextPayloadPart, stream *payloadStream) (*PayloadPart,
 	}
 	stream.nextOffset += n
 
-	return &PayloadPart{
+	part := &PayloadPart{
 		Data:    data[:n],
 		Trailer: nil,
 		IsLast:  last,
-	}, nil
+	}
+	stream.lastOffset, stream.lastPart = offset, part
+
+	return part, nil
 }
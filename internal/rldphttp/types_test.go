@@ -0,0 +1,60 @@
+package rldphttp
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestHandleGetPart(t *testing.T) {
+	// like dataStreamer, the last data comes with io.EOF
+	stream := &payloadStream{Data: io.NopCloser(iotest.DataErrReader(bytes.NewReader([]byte("abcdefghij"))))}
+	get := func(seqno int32) (*PayloadPart, error) {
+		return handleGetPart(GetNextPayloadPart{Seqno: seqno, MaxChunkSize: 4}, stream)
+	}
+
+	cases := []struct {
+		seqno int32
+		data  string
+		last  bool
+		err   bool
+	}{
+		{seqno: 0, data: "abcd"},
+		{seqno: 0, data: "abcd"}, // asked again after a lost answer
+		{seqno: 2, err: true},
+		{seqno: 1, data: "efgh"},
+		{seqno: 0, err: true},
+		{seqno: 2, data: "ij", last: true},
+		{seqno: 2, data: "ij", last: true},
+	}
+	for i, c := range cases {
+		part, err := get(c.seqno)
+		if c.err {
+			if err == nil {
+				t.Errorf("%d: expected error for seqno %d", i, c.seqno)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%d: %s", i, err)
+		}
+		if string(part.Data) != c.data || part.IsLast != c.last {
+			t.Errorf("%d: expected %q last=%v, but got %q last=%v", i, c.data, c.last, part.Data, part.IsLast)
+		}
+	}
+}
+
+func TestHandleGetPartLargeOffset(t *testing.T) {
+	// past 2 GiB, where seqno*chunk overflows int32
+	const seqno, chunk = 20000, _ChunkSize
+	stream := &payloadStream{Data: io.NopCloser(bytes.NewReader([]byte("tail"))), nextOffset: seqno * chunk}
+
+	part, err := handleGetPart(GetNextPayloadPart{Seqno: seqno, MaxChunkSize: chunk}, stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(part.Data) != "tail" {
+		t.Errorf("Expected the part at offset %d, but got %q", seqno*chunk, part.Data)
+	}
+}
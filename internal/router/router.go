@@ -0,0 +1,197 @@
+// Package router matches requests against routes in order, by path prefix and host pattern
+package router
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Route sends the requests for Path, and Host if set, to Handler. A rewriting route changes
+// the path and lets the routes after it match the new one.
+type Route struct {
+	Path string
+	Host string
+
+	Handler http.Handler
+	Rewrite func(path string) (string, bool)
+}
+
+// Router tries the routes in order, requests no route matches get NotFound
+type Router struct {
+	Routes   []Route
+	NotFound http.Handler
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host := Hostname(r.Host)
+
+	for i := 0; i < len(rt.Routes); i++ {
+		route := rt.Routes[i]
+		if !MatchHost(route.Host, host) || !MatchPath(route.Path, r.URL.Path) {
+			continue
+		}
+
+		if route.Rewrite == nil {
+			route.Handler.ServeHTTP(w, r)
+			return
+		}
+
+		if path, ok := route.Rewrite(r.URL.Path); ok {
+			r = rewrite(r, path)
+		}
+	}
+
+	if rt.NotFound != nil {
+		rt.NotFound.ServeHTTP(w, r)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// rewrite returns a copy of r for the path, which can carry a query to replace the original
+func rewrite(r *http.Request, path string) *http.Request {
+	r2 := r.Clone(r.Context())
+
+	u := *r.URL
+	if p, query, ok := strings.Cut(path, "?"); ok {
+		path, u.RawQuery = p, query
+	}
+	u.Path, u.RawPath = path, ""
+	r2.URL = &u
+
+	return r2
+}
+
+// Hostname is the host of a Host header, lower case and without the port
+func Hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// MatchHost reports if the host matches the pattern: empty matches any host,
+// *.site.ton matches the subdomains of site.ton but not site.ton itself
+func MatchHost(pattern, host string) bool {
+	if pattern == "" {
+		return true
+	}
+
+	pattern = strings.ToLower(pattern)
+	if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+		return strings.HasSuffix(host, suffix) && len(host) > len(suffix)
+	}
+
+	return host == pattern
+}
+
+// MatchPath reports if the path is the prefix or below it, /docs matches /docs and /docs/a
+// but not /docsets, / matches every path
+func MatchPath(prefix, path string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+
+	return len(path) == len(prefix) || strings.HasSuffix(prefix, "/") || path[len(prefix)] == '/'
+}
+
+// Canonical redirects requests for the .adnl address to the same URL on host,
+// so visitors and search engines see the .ton name
+func Canonical(host string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(Hostname(r.Host), ".adnl") {
+			http.Redirect(w, r, "http://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Static serves the files of fsys below prefix, /assets/a.css of the prefix /assets is a.css,
+// directories are not listed
+func Static(prefix string, fsys fs.FS) http.Handler {
+	files := http.FileServer(http.FS(fsys))
+
+	return http.StripPrefix(strings.TrimSuffix(prefix, "/"), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "" || strings.HasSuffix(r.URL.Path, "/") {
+			http.NotFound(w, r)
+			return
+		}
+
+		files.ServeHTTP(w, r)
+	}))
+}
+
+// DefaultProxyTimeout bounds an upstream request when its route sets no timeout
+const DefaultProxyTimeout = 30 * time.Second
+
+// Proxy passes requests to the upstream, the request path is appended to the path of target.
+// Caching is up to the upstream, the Cache-Control set for the path is dropped. The upstream
+// gets timeout to answer, independent of the deadline of the RLDP request.
+func Proxy(target *url.URL, timeout time.Duration) http.Handler {
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+			r.SetXForwarded()
+
+			// RLDP requests have no RemoteAddr, the visitor's node address comes in a header
+			if ip := r.In.Header.Get("X-Adnl-Ip"); ip != "" {
+				r.Out.Header.Set("X-Forwarded-For", ip)
+			}
+		},
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), timeout)
+		defer cancel()
+
+		w.Header().Del("Cache-Control")
+		proxy.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Redirect sends every request to the target URL with the code
+func Redirect(target string, code int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target, code)
+	})
+}
+
+// Respond answers every request with the same code and body
+func Respond(code int, body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(code)
+		if r.Method != http.MethodHead {
+			_, _ = io.WriteString(w, body)
+		}
+	})
+}
+
+// Rewriter replaces the matches of the expression in the path with replacement,
+// which can refer to groups like $1
+func Rewriter(match, replacement string) (func(path string) (string, bool), error) {
+	re, err := regexp.Compile(match)
+	if err != nil {
+		return nil, fmt.Errorf("invalid match: %w", err)
+	}
+
+	return func(path string) (string, bool) {
+		if !re.MatchString(path) {
+			return path, false
+		}
+
+		return re.ReplaceAllString(path, replacement), true
+	}, nil
+}
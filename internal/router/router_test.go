@@ -0,0 +1,184 @@
+package router
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestMatch(t *testing.T) {
+	paths := []struct {
+		prefix, path string
+		match        bool
+	}{
+		{"/", "/", true},
+		{"/", "/a/b", true},
+		{"/docs", "/docs", true},
+		{"/docs", "/docs/a", true},
+		{"/docs", "/docsets", false},
+		{"/docs/", "/docs/a", true},
+		{"/docs/", "/docs", false},
+	}
+	for _, c := range paths {
+		if got := MatchPath(c.prefix, c.path); got != c.match {
+			t.Errorf("MatchPath(%q, %q) = %v", c.prefix, c.path, got)
+		}
+	}
+
+	hosts := []struct {
+		pattern, host string
+		match         bool
+	}{
+		{"", "any.ton", true},
+		{"site.ton", "site.ton", true},
+		{"Site.TON", "site.ton", true},
+		{"site.ton", "www.site.ton", false},
+		{"*.site.ton", "www.site.ton", true},
+		{"*.site.ton", "site.ton", false},
+		{"*.site.ton", "evilsite.ton", false},
+	}
+	for _, c := range hosts {
+		if got := MatchHost(c.pattern, c.host); got != c.match {
+			t.Errorf("MatchHost(%q, %q) = %v", c.pattern, c.host, got)
+		}
+	}
+
+	if got := Hostname("WWW.Site.ton.:80"); got != "www.site.ton" {
+		t.Errorf("Expected www.site.ton, but got %q", got)
+	}
+}
+
+func TestRouter(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "upstream "+r.URL.Path)
+	}))
+	defer upstream.Close()
+	target, _ := url.Parse(upstream.URL)
+
+	rewrite, err := Rewriter(`^/post/(\d+)$`, "/blog/$1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := fstest.MapFS{"a.css": {Data: []byte("css")}}
+
+	rt := &Router{Routes: []Route{
+		{Path: "/", Host: "old.ton", Handler: Redirect("http://new.ton/", http.StatusMovedPermanently)},
+		{Path: "/post", Rewrite: rewrite},
+		{Path: "/blog", Handler: Respond(http.StatusOK, "blog")},
+		{Path: "/assets", Handler: Static("/assets", files)},
+		{Path: "/api/", Handler: Proxy(target, DefaultProxyTimeout)},
+		{Path: "/health", Handler: Respond(http.StatusOK, "ok")},
+	}}
+
+	cases := []struct {
+		host, path string
+		code       int
+		body       string
+	}{
+		{"old.ton", "/any", http.StatusMovedPermanently, ""},
+		{"site.ton", "/health", http.StatusOK, "ok"},
+		{"site.ton", "/post/12", http.StatusOK, "blog"},
+		{"site.ton", "/post/abc", http.StatusNotFound, ""},
+		{"site.ton", "/assets/a.css", http.StatusOK, "css"},
+		{"site.ton", "/assets/", http.StatusNotFound, ""},
+		{"site.ton", "/api/v1", http.StatusOK, "upstream /api/v1"},
+		{"site.ton", "/missing", http.StatusNotFound, ""},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://"+c.host+c.path, nil))
+
+		if w.Code != c.code || (c.body != "" && w.Body.String() != c.body) {
+			t.Errorf("%s%s: expected %d %q, but got %d %q", c.host, c.path, c.code, c.body, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestProxy(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		_, _ = io.WriteString(w, r.Header.Get("X-Forwarded-For"))
+	}))
+	defer upstream.Close()
+	target, _ := url.Parse(upstream.URL)
+
+	proxy := Proxy(target, 50*time.Millisecond)
+
+	// RLDP requests carry the visitor's address in X-Adnl-Ip and a RemoteAddr without a port
+	r := httptest.NewRequest(http.MethodGet, "http://site.ton/", nil)
+	r.RemoteAddr = "1.2.3.4"
+	r.Header.Set("X-Adnl-Ip", "1.2.3.4")
+	r.Header.Set("X-Forwarded-For", "6.6.6.6")
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, r)
+	if w.Body.String() != "1.2.3.4" {
+		t.Errorf("Expected X-Forwarded-For 1.2.3.4, but got %q", w.Body.String())
+	}
+
+	// the upstream gets its own timeout, not the one of the request
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://site.ton/", nil).WithContext(ctx))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200 for a canceled request context, but got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://site.ton/slow", nil))
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("Expected 502 for a slow upstream, but got %d", w.Code)
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	h := Canonical("site.ton", Respond(http.StatusOK, "site"))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://abc.adnl/blog/?page=2", nil))
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "http://site.ton/blog/?page=2" {
+		t.Errorf("Expected a redirect to site.ton, but got %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://site.ton/", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected the site on its own name, but got %d", w.Code)
+	}
+}
+
+func TestRouterHosts(t *testing.T) {
+	rt := &Router{
+		Routes: []Route{
+			{Path: "/", Host: "site.ton", Handler: Respond(http.StatusOK, "site")},
+			{Path: "/", Host: "*.site.ton", Handler: Respond(http.StatusOK, "sub")},
+		},
+		NotFound: Respond(http.StatusOK, "default"),
+	}
+
+	cases := map[string]string{
+		"site.ton":      "site",
+		"SITE.TON:80":   "site",
+		"blog.site.ton": "sub",
+		"abc.adnl":      "default",
+		"other.ton":     "default",
+	}
+	for host, want := range cases {
+		w := httptest.NewRecorder()
+		rt.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://"+host+"/", nil))
+		if w.Body.String() != want {
+			t.Errorf("%s: expected %q, but got %q", host, want, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Package secure sets the security headers of the site and turns away requests for paths
+// that try to leave it
+package secure
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// VersionHeader carries the version of the site software, TON proxies show it to visitors
+// and expect it to start with "Commit: "
+const VersionHeader = "Ton-Proxy-Site-Version"
+
+// serverHeaders name the software behind a proxied upstream, they are dropped from responses
+var serverHeaders = []string{"Server", "X-Powered-By", "X-AspNet-Version", "X-AspNetMvc-Version", "X-Generator"}
+
+// Headers are added to every response, an empty policy is left out and a policy the
+// handler set itself, like a proxied upstream's, is kept
+type Headers struct {
+	ContentSecurityPolicy string
+	ReferrerPolicy        string
+	PermissionsPolicy     string
+
+	Version string
+}
+
+// Handler checks the request path before next sees it: encoded slashes, backslashes, NUL bytes
+// and dot segments leaving the root get 400, other dot segments and repeated slashes are
+// redirected to the clean path
+func Handler(h Headers, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &responseWriter{ResponseWriter: w, headers: &h}
+		defer sw.finish()
+
+		clean, ok := CleanPath(r.URL)
+		if !ok {
+			http.Error(sw, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		if clean != r.URL.Path {
+			u := url.URL{Path: clean, RawQuery: r.URL.RawQuery}
+			http.Redirect(sw, r, u.RequestURI(), http.StatusMovedPermanently)
+			return
+		}
+
+		next.ServeHTTP(sw, r)
+	})
+}
+
+// CleanPath is the path of u with dot segments resolved and repeated slashes collapsed,
+// keeping the trailing slash. It is not ok for paths the file handlers must never see.
+func CleanPath(u *url.URL) (string, bool) {
+	p := u.Path
+	if !strings.HasPrefix(p, "/") || strings.ContainsAny(p, "\x00\\") {
+		return "", false
+	}
+
+	raw := strings.ToLower(u.EscapedPath())
+	if strings.Contains(raw, "%2f") || strings.Contains(raw, "%5c") || strings.Contains(raw, "%00") {
+		return "", false
+	}
+
+	depth := 0
+	for _, segment := range strings.Split(p[1:], "/") {
+		switch segment {
+		case "":
+		case ".", "..":
+			// an escaped dot segment only hides a traversal, no link has it
+			if strings.Contains(raw, "%2e") {
+				return "", false
+			}
+			if segment == ".." {
+				if depth--; depth < 0 {
+					return "", false
+				}
+			}
+		default:
+			depth++
+		}
+	}
+
+	clean := path.Clean(p)
+	if strings.HasSuffix(p, "/") && clean != "/" {
+		clean += "/"
+	}
+
+	return clean, true
+}
+
+// responseWriter adds the headers when the response starts
+type responseWriter struct {
+	http.ResponseWriter
+	headers *Headers
+
+	wroteHeader bool
+}
+
+func (s *responseWriter) WriteHeader(code int) {
+	if !s.wroteHeader {
+		s.wroteHeader = true
+		s.setHeaders()
+	}
+
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *responseWriter) Write(p []byte) (int, error) {
+	if !s.wroteHeader {
+		s.WriteHeader(http.StatusOK)
+	}
+
+	return s.ResponseWriter.Write(p)
+}
+
+// finish sets the headers of a response the handler left empty, net/http sends it after
+func (s *responseWriter) finish() {
+	if !s.wroteHeader {
+		s.wroteHeader = true
+		s.setHeaders()
+	}
+}
+
+func (s *responseWriter) setHeaders() {
+	h := s.Header()
+	for _, name := range serverHeaders {
+		h.Del(name)
+	}
+
+	h.Set("X-Content-Type-Options", "nosniff")
+	for name, value := range map[string]string{
+		"Content-Security-Policy": s.headers.ContentSecurityPolicy,
+		"Referrer-Policy":         s.headers.ReferrerPolicy,
+		"Permissions-Policy":      s.headers.PermissionsPolicy,
+	} {
+		if value != "" && h.Get(name) == "" {
+			h.Set(name, value)
+		}
+	}
+
+	if s.headers.Version != "" {
+		h.Set(VersionHeader, "Commit: "+s.headers.Version)
+	}
+}
+
+func (s *responseWriter) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
@@ -0,0 +1,108 @@
+package secure
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestCleanPath(t *testing.T) {
+	cases := map[string]struct {
+		clean string
+		ok    bool
+	}{
+		"/":                  {"/", true},
+		"/a/b.html":          {"/a/b.html", true},
+		"/a/":                {"/a/", true},
+		"/a//b/./c/":         {"/a/b/c/", true},
+		"/a/../b":            {"/b", true},
+		"/a%20b.txt":         {"/a%20b.txt", true},
+		"/..":                {"", false},
+		"/a/../../b":         {"", false},
+		"/a/%2e%2e/b":        {"", false},
+		"/a/%2E./b":          {"", false},
+		"/a/%2e/b":           {"", false},
+		"/a%2fb":             {"", false},
+		"/a%5cb":             {"", false},
+		"/a\\b":              {"", false},
+		"/a%00.html":         {"", false},
+		"/a/..%2f..%2fetc":   {"", false},
+		"/a/..%252f..%252fb": {"/a/..%252f..%252fb", true},
+	}
+
+	for target, c := range cases {
+		u, err := url.Parse(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		clean, ok := CleanPath(u)
+		if ok != c.ok {
+			t.Errorf("%s: expected ok %v, but got %v", target, c.ok, ok)
+		}
+		if ok && (&url.URL{Path: clean}).EscapedPath() != c.clean {
+			t.Errorf("%s: expected %q, but got %q", target, c.clean, clean)
+		}
+	}
+}
+
+func TestHandlerHeaders(t *testing.T) {
+	headers := Headers{
+		ContentSecurityPolicy: "default-src 'self'",
+		ReferrerPolicy:        "no-referrer",
+		Version:               "v1.2.3",
+	}
+
+	h := Handler(headers, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "nginx/1.25")
+		w.Header().Set("X-Powered-By", "PHP/8.3")
+		if r.URL.Path == "/app" {
+			w.Header().Set("Content-Security-Policy", "default-src *")
+		}
+		if r.URL.Path != "/empty" {
+			_, _ = w.Write([]byte("ok"))
+		}
+	}))
+
+	for _, target := range []string{"/", "/app", "/empty"} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+
+		want := map[string]string{
+			"X-Content-Type-Options":  "nosniff",
+			"Content-Security-Policy": "default-src 'self'",
+			"Referrer-Policy":         "no-referrer",
+			"Permissions-Policy":      "",
+			"Server":                  "",
+			"X-Powered-By":            "",
+			VersionHeader:             "Commit: v1.2.3",
+		}
+		if target == "/app" {
+			want["Content-Security-Policy"] = "default-src *"
+		}
+		for name, value := range want {
+			if got := w.Header().Get(name); got != value {
+				t.Errorf("%s: expected %s %q, but got %q", target, name, value, got)
+			}
+		}
+	}
+}
+
+func TestHandlerRedirect(t *testing.T) {
+	h := Handler(Headers{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Expected %s not to reach the handler", r.URL)
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/blog//./post/?page=2", nil))
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/blog/post/?page=2" {
+		t.Errorf("Expected a redirect to the clean path, but got %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/static/%2e%2e/%2e%2e/etc/passwd", nil))
+	if w.Code != http.StatusBadRequest || w.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Errorf("Expected 400 with the headers, but got %d %v", w.Code, w.Header())
+	}
+}
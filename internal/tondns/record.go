@@ -0,0 +1,62 @@
+package tondns
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+const (
+	opChangeDNSRecord = 0x4eb1f0f9
+	tagADNLAddress    = 0xad01
+)
+
+// DefaultAmount is attached to the change message to pay for its processing, in nanotons.
+const DefaultAmount uint64 = 50_000_000
+
+// CategorySite is the record key TON Sites are resolved by, sha256("site").
+var CategorySite = sha256.Sum256([]byte("site"))
+
+// SiteRecord builds a dns_adnl_address record pointing to the given ADNL id.
+func SiteRecord(adnlID []byte) (*cell.Cell, error) {
+	if len(adnlID) != 32 {
+		return nil, errors.New("adnl id should be 32 bytes")
+	}
+
+	return cell.BeginCell().
+		MustStoreUInt(tagADNLAddress, 16).
+		MustStoreSlice(adnlID, 256).
+		MustStoreUInt(0, 8). // no proto list
+		EndCell(), nil
+}
+
+// ChangeSiteRecord builds the change_dns_record message body which sets
+// the site category of a domain to the given ADNL id.
+func ChangeSiteRecord(adnlID []byte, queryID uint64) (*cell.Cell, error) {
+	record, err := SiteRecord(adnlID)
+	if err != nil {
+		return nil, err
+	}
+
+	return cell.BeginCell().
+		MustStoreUInt(opChangeDNSRecord, 32).
+		MustStoreUInt(queryID, 64).
+		MustStoreSlice(CategorySite[:], 256).
+		MustStoreMaybeRef(record).
+		EndCell(), nil
+}
+
+// TransferLink returns a ton://transfer deep link which sends body to the domain NFT,
+// wallets show it as a regular transfer to be signed by the domain owner.
+func TransferLink(domain *address.Address, amount uint64, body *cell.Cell) string {
+	q := url.Values{}
+	q.Set("amount", fmt.Sprint(amount))
+	q.Set("bin", base64.URLEncoding.EncodeToString(body.ToBOC()))
+
+	return "ton://transfer/" + domain.String() + "?" + q.Encode()
+}
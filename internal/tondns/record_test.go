@@ -0,0 +1,85 @@
+package tondns
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+func TestChangeSiteRecord(t *testing.T) {
+	id := bytes.Repeat([]byte{0xAB}, 32)
+
+	body, err := ChangeSiteRecord(id, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := body.BeginParse()
+	if op := s.MustLoadUInt(32); op != opChangeDNSRecord {
+		t.Errorf("Expected op %x, but got %x", opChangeDNSRecord, op)
+	}
+	if qid := s.MustLoadUInt(64); qid != 7 {
+		t.Errorf("Expected query id 7, but got %d", qid)
+	}
+	if key := s.MustLoadSlice(256); !bytes.Equal(key, CategorySite[:]) {
+		t.Errorf("Expected site category key, but got %x", key)
+	}
+
+	rec := s.MustLoadMaybeRef()
+	if rec == nil {
+		t.Fatal("Expected record to be set")
+	}
+	if tag := rec.MustLoadUInt(16); tag != tagADNLAddress {
+		t.Errorf("Expected tag %x, but got %x", tagADNLAddress, tag)
+	}
+	if adnl := rec.MustLoadSlice(256); !bytes.Equal(adnl, id) {
+		t.Errorf("Expected adnl id %x, but got %x", id, adnl)
+	}
+	if fl := rec.MustLoadUInt(8); fl != 0 {
+		t.Errorf("Expected no flags, but got %d", fl)
+	}
+}
+
+func TestChangeSiteRecordWrongID(t *testing.T) {
+	if _, err := ChangeSiteRecord([]byte{1, 2, 3}, 0); err == nil {
+		t.Error("Expected error for short adnl id")
+	}
+}
+
+func TestTransferLink(t *testing.T) {
+	nft := address.MustParseAddr("EQBvW8Z5huBkMJYdnfAEM5JqTNkuWX3diqYENkWsIL0XggGG")
+	body, err := ChangeSiteRecord(bytes.Repeat([]byte{1}, 32), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	link := TransferLink(nft, DefaultAmount, body)
+	if !strings.HasPrefix(link, "ton://transfer/"+nft.String()+"?") {
+		t.Fatalf("Unexpected link %s", link)
+	}
+
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if amount := u.Query().Get("amount"); amount != "50000000" {
+		t.Errorf("Expected amount 50000000, but got %s", amount)
+	}
+
+	boc, err := base64.URLEncoding.DecodeString(u.Query().Get("bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := cell.FromBOC(boc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(c.Hash(), body.Hash()) {
+		t.Error("Expected link payload to match the body")
+	}
+}
@@ -0,0 +1,135 @@
+package vanity
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	rldphttp "github.com/ad/ton-site-ha/internal/rldphttp"
+)
+
+// AddressLength is the length of a serialized ADNL address without the .adnl suffix
+const AddressLength = 55
+
+const alphabet = "abcdefghijklmnopqrstuvwxyz234567"
+
+// firstChars are the only possible first characters of an address:
+// they hold the low 3 bits of the 0x2d tag byte followed by 2 bits of the id
+const firstChars = "uvwx"
+
+// Pattern is what the searched address should look like
+type Pattern struct {
+	Text     string
+	Contains bool
+}
+
+// Validate checks that the pattern can ever match an address
+func (p Pattern) Validate() error {
+	if p.Text == "" {
+		return errors.New("pattern is empty")
+	}
+	if len(p.Text) > AddressLength {
+		return fmt.Errorf("pattern is longer than %d characters", AddressLength)
+	}
+
+	for i, c := range p.Text {
+		if !strings.ContainsRune(alphabet, c) {
+			return fmt.Errorf("character %q at position %d is not in the base32 alphabet a-z, 2-7", c, i+1)
+		}
+	}
+
+	if !p.Contains && !strings.ContainsRune(firstChars, rune(p.Text[0])) {
+		return fmt.Errorf("addresses always start with one of %q, use contains search for other patterns", firstChars)
+	}
+
+	return nil
+}
+
+// Attempts estimates how many keys should be tried on average to find a match
+func (p Pattern) Attempts() float64 {
+	n := float64(len(p.Text))
+	if !p.Contains {
+		return float64(len(firstChars)) * math.Pow(32, n-1)
+	}
+
+	return math.Pow(32, n) / (AddressLength - n + 1)
+}
+
+// Match reports whether the address matches the pattern
+func (p Pattern) Match(addr string) bool {
+	if p.Contains {
+		return strings.Contains(addr, p.Text)
+	}
+	return strings.HasPrefix(addr, p.Text)
+}
+
+// Result is the key found by Search
+type Result struct {
+	Seed    []byte
+	Address string
+}
+
+// Search tries random keys on the given number of goroutines until one matches the pattern
+// or ctx is done. Tried counts attempts and can be read concurrently to report progress.
+func Search(ctx context.Context, p Pattern, threads int, tried *atomic.Uint64) (*Result, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		once   sync.Once
+		result *Result
+		wg     sync.WaitGroup
+	)
+
+	for i := 0; i < max(threads, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			seed := make([]byte, ed25519.SeedSize)
+			for ctx.Err() == nil {
+				if _, err := rand.Read(seed); err != nil {
+					continue
+				}
+
+				addr, err := address(seed)
+				tried.Add(1)
+				if err != nil || !p.Match(addr) {
+					continue
+				}
+
+				once.Do(func() {
+					result = &Result{Seed: append([]byte{}, seed...), Address: addr}
+					cancel()
+				})
+				return
+			}
+		}()
+	}
+	wg.Wait()
+
+	if result == nil {
+		return nil, ctx.Err()
+	}
+
+	return result, nil
+}
+
+func address(seed []byte) (string, error) {
+	a, err := rldphttp.ADNLAddressFromPublicKey(ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey))
+	if err != nil {
+		return "", err
+	}
+
+	return a.String(), nil
+}
@@ -0,0 +1,73 @@
+package vanity
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPatternValidate(t *testing.T) {
+	valid := []Pattern{
+		{Text: "u"},
+		{Text: "xton"},
+		{Text: "ton", Contains: true},
+		{Text: "a2b7", Contains: true},
+	}
+	for _, p := range valid {
+		if err := p.Validate(); err != nil {
+			t.Errorf("%+v: unexpected error %s", p, err)
+		}
+	}
+
+	invalid := []Pattern{
+		{Text: ""},
+		{Text: "ton"},                   // prefix can't start with t
+		{Text: "site1", Contains: true}, // 1 is not in base32
+		{Text: "Site", Contains: true},
+		{Text: strings.Repeat("u", AddressLength+1)},
+	}
+	for _, p := range invalid {
+		if err := p.Validate(); err == nil {
+			t.Errorf("%+v: expected error", p)
+		}
+	}
+}
+
+func TestPatternAttempts(t *testing.T) {
+	if a := (Pattern{Text: "u"}).Attempts(); a != 4 {
+		t.Errorf("Expected 4 attempts for one char prefix, but got %f", a)
+	}
+	if a := (Pattern{Text: "uu"}).Attempts(); a != 128 {
+		t.Errorf("Expected 128 attempts for two char prefix, but got %f", a)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var tried atomic.Uint64
+	p := Pattern{Text: "ua"}
+
+	res, err := Search(ctx, p, 2, &tried)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(res.Address, "ua") || len(res.Address) != AddressLength {
+		t.Errorf("Unexpected address %s", res.Address)
+	}
+
+	addr, err := address(res.Seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr != res.Address {
+		t.Errorf("Expected seed to produce %s, but got %s", res.Address, addr)
+	}
+	if tried.Load() == 0 {
+		t.Error("Expected attempts to be counted")
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ad/ton-site-ha/config"
+	rldphttp "github.com/ad/ton-site-ha/internal/rldphttp"
+)
+
+func keyCommand(args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: key export|import|encrypt [--force]|passphrase [--force]|rotate")
+	}
+
+	switch args[1] {
+	case "export":
+		conf, err := config.LoadConfig(args[1:], version)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(conf.Key)
+		return nil
+	case "import":
+		conf, err := config.Parse(args[1:], version)
+		if err != nil {
+			return err
+		}
+
+		if conf.KeyStore == "" {
+			return errors.New("KEY_STORE is not set")
+		}
+
+		// the key is read from stdin to keep it out of the process list and shell history
+		fmt.Fprintln(os.Stderr, "reading key from stdin")
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read key: %w", err)
+		}
+
+		key, err := config.ParseKey(string(data))
+		if err != nil {
+			return fmt.Errorf("failed to parse key: %w", err)
+		}
+
+		passphrase, err := conf.Passphrase()
+		if err != nil {
+			return err
+		}
+
+		if err := config.SaveKeyStore(conf.KeyStore, hex.EncodeToString(key.Seed()), passphrase); err != nil {
+			return err
+		}
+
+		addr, err := adnlAddress(key)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("key imported to", conf.KeyStore+", site address is", addr+".adnl")
+		return nil
+	case "encrypt", "passphrase":
+		// --force replaces a store holding another key with KEY, KEY_FILE or MNEMONIC
+		force := slices.ContainsFunc(args[2:], func(arg string) bool { return arg == "-force" || arg == "--force" })
+		args = slices.DeleteFunc(args, func(arg string) bool { return arg == "-force" || arg == "--force" })
+
+		// the current key is loaded as usual, with KEY_PASSPHRASE if the store is already encrypted
+		conf, err := config.LoadConfig(args[1:], version)
+		if err != nil {
+			return err
+		}
+
+		if conf.KeyStore == "" {
+			return errors.New("KEY_STORE is not set")
+		}
+
+		if !force {
+			current, err := conf.Passphrase()
+			if err != nil {
+				return err
+			}
+
+			err = config.CheckKeyStore(conf.KeyStore, conf.Key, current)
+			if errors.Is(err, config.ErrKeyStoreMismatch) {
+				return fmt.Errorf("%s holds a different key than KEY, KEY_FILE or MNEMONIC, replacing it changes "+
+					"the site address; run with --force to replace it anyway", conf.KeyStore)
+			} else if err != nil {
+				return fmt.Errorf("failed to check key store %s: %w", conf.KeyStore, err)
+			}
+		}
+
+		passphrase, err := readNewPassphrase()
+		if err != nil {
+			return err
+		}
+
+		if err := config.ReplaceKeyStore(conf.KeyStore, conf.Key, passphrase); err != nil {
+			return err
+		}
+
+		fmt.Println("key store", conf.KeyStore, "is encrypted, set KEY_PASSPHRASE or KEY_PASSPHRASE_FILE to start the server")
+		fmt.Println("remove KEY and KEY_FILE from the options if set, to keep only the encrypted copy")
+		return nil
+	case "rotate":
+		return rotateKey(args[1:])
+	}
+
+	return fmt.Errorf("unknown key command %q", args[1])
+}
+
+// rotateKey moves the stored key to the previous key store and generates a new one,
+// the server then answers on both addresses until the overlap period ends
+func rotateKey(args []string) error {
+	conf, err := config.Parse(args, version)
+	if err != nil {
+		return err
+	}
+
+	// KEY, KEY_FILE and MNEMONIC win over the key store, so the server would keep the old key
+	if conf.Key != "" || conf.KeyFile != "" || conf.Mnemonic != "" || conf.KeyStore == "" {
+		return errors.New("rotation works with the key store, KEY, KEY_FILE and MNEMONIC should not be set")
+	}
+	if conf.PreviousKey != "" || conf.PreviousKeyStore == "" {
+		return errors.New("set PREVIOUS_KEY_STORE and remove PREVIOUS_KEY to rotate")
+	}
+	if _, err := os.Stat(conf.PreviousKeyStore); err == nil {
+		return fmt.Errorf("rotation is already in progress, remove %s once the old address is retired", conf.PreviousKeyStore)
+	}
+
+	// load the current key to make sure the passphrase is right before touching anything
+	if conf, err = config.LoadConfig(args, version); err != nil {
+		return err
+	}
+
+	passphrase, err := conf.Passphrase()
+	if err != nil {
+		return err
+	}
+
+	newKey, err := config.NewKey()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	rotation := config.Rotation{Start: now, End: now.Add(config.DefaultRotationOverlap)}
+	if err := config.SaveRotation(conf.PreviousKeyStore, rotation); err != nil {
+		return fmt.Errorf("failed to record rotation: %w", err)
+	}
+
+	if err := os.Rename(conf.KeyStore, conf.PreviousKeyStore); err != nil {
+		_ = os.Remove(config.RotationFile(conf.PreviousKeyStore))
+		return fmt.Errorf("failed to move current key: %w", err)
+	}
+
+	if err := config.SaveKeyStore(conf.KeyStore, newKey, passphrase); err != nil {
+		_ = os.Rename(conf.PreviousKeyStore, conf.KeyStore)
+		_ = os.Remove(config.RotationFile(conf.PreviousKeyStore))
+		return err
+	}
+
+	// read the config again to report the overlap exactly as the server will see it
+	if conf, err = config.LoadConfig(args, version); err != nil {
+		return err
+	}
+
+	key, _ := config.ParseKey(conf.Key)
+	oldKey, _ := config.ParseKey(conf.PreviousKey)
+	newAddr, _ := adnlAddress(key)
+	oldAddr, _ := adnlAddress(oldKey)
+
+	fmt.Println("new site address is", newAddr+".adnl")
+	fmt.Println("previous address", oldAddr+".adnl", "keeps working until", conf.RotationEnd.Format(time.DateTime))
+	fmt.Println("restart the add-on, then update the site record of your domain with `app dns-record` before that date")
+	return nil
+}
+
+// readNewPassphrase takes the new passphrase from NEW_KEY_PASSPHRASE or the first line of stdin
+func readNewPassphrase() (string, error) {
+	if passphrase := os.Getenv("NEW_KEY_PASSPHRASE"); passphrase != "" {
+		return passphrase, nil
+	}
+
+	fmt.Fprintln(os.Stderr, "reading new passphrase from stdin")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+
+	passphrase := strings.TrimRight(line, "\r\n")
+	if passphrase == "" {
+		return "", errors.New("passphrase is empty")
+	}
+
+	return passphrase, nil
+}
+
+func adnlID(key ed25519.PrivateKey) ([]byte, error) {
+	a, err := rldphttp.ADNLAddressFromPublicKey(key.Public().(ed25519.PublicKey))
+	if err != nil {
+		return nil, err
+	}
+
+	return a[:], nil
+}
+
+func adnlAddress(key ed25519.PrivateKey) (string, error) {
+	a, err := rldphttp.ADNLAddressFromPublicKey(key.Public().(ed25519.PublicKey))
+	if err != nil {
+		return "", err
+	}
+
+	return a.String(), nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+
+	"github.com/ad/ton-site-ha/config"
+)
+
+// keygenCommand prints a new key with its address without touching the key store
+func keygenCommand(args []string) error {
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	mnemonic := flags.Bool("mnemonic", false, "generate a 24 word mnemonic instead of a hex seed")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	var secret, seed string
+	if *mnemonic {
+		words, err := config.NewMnemonic()
+		if err != nil {
+			return err
+		}
+
+		raw, err := config.MnemonicToSeed(words)
+		if err != nil {
+			return err
+		}
+		secret, seed = words, hex.EncodeToString(raw)
+	} else {
+		key, err := config.NewKey()
+		if err != nil {
+			return err
+		}
+		secret, seed = key, key
+	}
+
+	key, err := config.ParseKey(seed)
+	if err != nil {
+		return err
+	}
+
+	addr, err := adnlAddress(key)
+	if err != nil {
+		return err
+	}
+
+	if *mnemonic {
+		fmt.Println("mnemonic:", secret)
+	} else {
+		fmt.Println("key:", secret)
+	}
+	fmt.Println("address:", addr+".adnl")
+
+	return nil
+}
@@ -1,28 +1,25 @@
 package main
 
 import (
-	"compress/gzip"
 	"context"
-	"crypto/ed25519"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"net"
 	"net/http"
 	"os"
-	"path/filepath"
+	"path"
 	"strings"
-	"text/template"
 	"time"
 
 	"github.com/ad/ton-site-ha/config"
-	"github.com/ad/ton-site-ha/site"
+	"github.com/ad/ton-site-ha/internal/autoindex"
+	"github.com/ad/ton-site-ha/internal/render"
+	"github.com/ad/ton-site-ha/internal/respcache"
 
-	"github.com/xssnick/tonutils-go/adnl"
-	"github.com/xssnick/tonutils-go/adnl/dht"
 	rldphttp "github.com/ad/ton-site-ha/internal/rldphttp"
 	"github.com/xssnick/tonutils-go/liteclient"
 )
@@ -31,19 +28,42 @@ var (
 	version = "dev"
 )
 
+var commands = map[string]func(args []string) error{
+	"address":    addressCommand,
+	"config":     configCommand,
+	"dns-record": dnsRecordCommand,
+	"key":        keyCommand,
+	"keygen":     keygenCommand,
+	"serve":      serveCommand,
+	"vanity":     vanityCommand,
+}
+
 func main() {
-	fmt.Printf("starting version %s\n", version)
+	// serve is the default, so `app -listenPort 9056` keeps working
+	cmd, args := serveCommand, os.Args
+	if len(os.Args) > 1 {
+		if c, ok := commands[os.Args[1]]; ok {
+			cmd, args = c, os.Args[1:]
+		}
+	}
 
-	conf, errInitConfig := config.InitConfig(os.Args, version)
-	if errInitConfig != nil {
-		log.Println("failed to load config:", errInitConfig.Error())
+	if err := cmd(args); err != nil {
+		log.Println(err.Error())
 		os.Exit(1)
 	}
+}
+
+func serveCommand(args []string) error {
+	fmt.Printf("starting version %s\n", version)
+
+	conf, err := config.InitConfig(args, version)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
 
-	key, err := getKey(conf.Key)
+	key, err := config.ParseKey(conf.Key)
 	if err != nil {
-		log.Println("failed to get key:", err.Error())
-		os.Exit(1)
+		return fmt.Errorf("failed to get key: %w", err)
 	}
 
 	// https://tonutils.com/ls/free-mainnet-config.json
@@ -63,38 +83,70 @@ func main() {
 		panic(err)
 	}
 
-	_, dhtAdnlKey, err := ed25519.GenerateKey(nil)
-	if err != nil {
-		panic("failed to generate ed25519 key for dht: " + err.Error())
+	mx := &siteHandler{}
+	if err := mx.apply(conf); err != nil {
+		return fmt.Errorf("failed to build site: %w", err)
 	}
 
-	gateway := adnl.NewGateway(dhtAdnlKey)
-	err = gateway.StartClient()
-	if err != nil {
-		panic("failed to load network config: " + err.Error())
-	}
+	s := rldphttp.NewServer(key, nil, mx)
+	s.SetExternalIP(net.ParseIP(getPublicIP()).To4())
 
-	dhtClient, err := dht.NewClientFromConfig(gateway, netCfg)
+	dhtClient, err := newDHTClient(conf, s, netCfg)
 	if err != nil {
 		panic(err)
 	}
+	s.SetDHT(dhtClient)
 
-	fs := http.FileServer(http.FS(site.Static))
+	var addr rldphttp.ADNLAddress
+	copy(addr[:], s.Address())
+	log.Println("Server's ADNL address is", addr.String()+".adnl ("+addr.Hex()+")")
 
-	mx := http.NewServeMux()
-	mx.HandleFunc("/", serveTemplate)
-	mx.Handle("/static/", neuter(fs))
+	var rot *rotation
+	if conf.PreviousKey != "" {
+		prevKey, err := config.ParseKey(conf.PreviousKey)
+		if err != nil {
+			return fmt.Errorf("failed to get previous key: %w", err)
+		}
 
-	s := rldphttp.NewServer(key, dhtClient, mx)
-	s.SetExternalIP(net.ParseIP(getPublicIP()).To4())
+		prevAddr, err := adnlAddress(prevKey)
+		if err != nil {
+			panic(err)
+		}
 
-	addr, err := rldphttp.SerializeADNLAddress(s.Address())
-	if err != nil {
-		panic(err)
+		rot = &rotation{
+			OldAddress: prevAddr + ".adnl",
+			NewAddress: addr.String() + ".adnl",
+			Mode:       conf.RotationMode,
+			Until:      conf.RotationEnd,
+		}
+
+		if rot.Until.IsZero() || time.Now().Before(rot.Until) {
+			s.AddIdentity(prevKey, rot.handler(mx), rot.Until)
+		}
+		go rot.remindLoop()
+	}
+
+	// the status page and metrics have their own port, LISTEN_PORT is the public RLDP one
+	var page http.Handler
+	if conf.StatusPage {
+		rec, err := newDNSRecord(s.Address(), conf.DomainNFTAddress)
+		if err != nil {
+			log.Println("failed to build dns record:", err.Error())
+		} else {
+			page = statusPage(rec, rot)
+		}
+	}
+	var metrics *respcache.Cache
+	if conf.Metrics {
+		metrics = mx.cache
 	}
-	log.Println("Server's ADNL address is", addr+".adnl ("+hex.EncodeToString(s.Address())+")")
+	if page != nil || metrics != nil {
+		go serveStatus(conf.StatusListen, page, metrics)
+	}
+
+	go watchConfig(context.Background(), args, conf, mx)
 
-	log.Println("Starting server on", addr+".adnl")
+	log.Println("Starting server on", addr.String()+".adnl")
 
 	err = s.ListenAndServe(net.JoinHostPort(conf.ListenHost, conf.ListenPort))
 	if errors.Is(err, http.ErrServerClosed) {
@@ -102,6 +154,8 @@ func main() {
 	} else if err != nil {
 		panic(fmt.Sprintf("error listening for server: %s", err))
 	}
+
+	return nil
 }
 
 func getPublicIP() string {
@@ -124,61 +178,44 @@ func getPublicIP() string {
 	return ip.Query
 }
 
-func getKey(data string) (ed25519.PrivateKey, error) {
-	dec, err := hex.DecodeString(data)
-	if err != nil {
-		return nil, err
-	}
-
-	return ed25519.NewKeyFromSeed(dec), nil
-}
-
-func serveTemplate(w http.ResponseWriter, r *http.Request) {
-	fmt.Printf("%+v\n", r)
-
-	if r.URL.Path == "" || r.URL.Path == "/" || strings.HasPrefix(r.URL.Path, "/?") {
-		r.URL.Path = "/index.html"
-	}
+// serveTemplate renders the page template at the request path into its layout
+func serveTemplate(set *render.Set) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		debugf("%+v\n", r)
 
-	lp := filepath.Join("templates", "layout.html")
-	fp := filepath.Join("templates", filepath.Clean(r.URL.Path))
+		name := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+		if name == "" {
+			name = "index.html"
+		}
 
-	tmpl, err := template.New("base.html").ParseFS(site.Templates, lp, fp)
-	if err != nil {
-		log.Print(err.Error())
-		http.Error(w, http.StatusText(404), 404)
+		// secure.Handler turns away traversals, the template set never sees a path leaving it
+		if !fs.ValidPath(name) {
+			set.Error(w, r, http.StatusNotFound)
 
-		return
-	}
+			return
+		}
 
-	if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
-		err = tmpl.ExecuteTemplate(w, "layout", nil)
+		page, err := set.Page(name)
 		if err != nil {
+			// only in dev mode, until the broken template is saved again
 			log.Print(err.Error())
-			http.Error(w, http.StatusText(404), 404)
+			set.Error(w, r, http.StatusServiceUnavailable)
 
 			return
 		}
+		if page == nil || strings.HasPrefix(name, "blog/") || strings.HasPrefix(name, render.ErrorsDir+"/") ||
+			strings.HasPrefix(name, path.Dir(autoindex.Template)+"/") {
+			set.Error(w, r, http.StatusNotFound)
 
-		return
-	}
-
-	w.Header().Set("Content-Encoding", "gzip")
-
-	w.Header().Set("Accept-Ranges", "bytes")
-	w.Header().Set("Content-Type", "text/html")
-	w.Header().Set("Ton-Proxy-Site-Version", "Commit: custom")
-	w.Header().Set("Vary", "Accept-Encoding")
-
-	gz := gzip.NewWriter(w)
-	defer gz.Close()
+			return
+		}
 
-	err = tmpl.ExecuteTemplate(gz, "layout", nil)
-	if err != nil {
-		log.Print(err.Error())
-		http.Error(w, http.StatusText(404), 404)
+		// pages reading .Visitor are made private by set.Write whatever they say here
+		if cc := page.Meta["cache-control"]; cc != "" {
+			w.Header().Set("Cache-Control", cc)
+		}
 
-		return
+		set.Write(w, r, http.StatusOK, page, set.Data(r, page))
 	}
 }
 
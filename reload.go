@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync/atomic"
+
+	"github.com/ad/ton-site-ha/config"
+	"github.com/ad/ton-site-ha/internal/blog"
+	"github.com/ad/ton-site-ha/internal/compress"
+	"github.com/ad/ton-site-ha/internal/content"
+	"github.com/ad/ton-site-ha/internal/httpcache"
+	"github.com/ad/ton-site-ha/internal/render"
+	"github.com/ad/ton-site-ha/internal/respcache"
+	"github.com/ad/ton-site-ha/internal/router"
+	"github.com/ad/ton-site-ha/internal/secure"
+	"github.com/ad/ton-site-ha/site"
+)
+
+// debug mirrors the DEBUG option, it can be switched by a config reload
+var debug atomic.Bool
+
+func debugf(format string, v ...any) {
+	if debug.Load() {
+		log.Printf(format, v...)
+	}
+}
+
+// siteHandler serves the site with the handler built from the current config,
+// a config reload swaps it without dropping requests in flight
+type siteHandler struct {
+	current atomic.Pointer[http.Handler]
+
+	// cache outlives reloads, so its metrics do, its responses are dropped on every reload
+	cache *respcache.Cache
+}
+
+func (s *siteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	(*s.current.Load()).ServeHTTP(w, r)
+}
+
+// apply builds the handler for conf and switches to it, the running one is kept on error
+func (s *siteHandler) apply(conf *config.Config) error {
+	h, err := newSiteHandler(conf)
+	if err != nil {
+		return err
+	}
+
+	if s.cache == nil {
+		s.cache = respcache.New(0)
+	}
+	// paths are checked before the cache keys responses by them
+	h = secure.Handler(secure.Headers{
+		ContentSecurityPolicy: conf.ContentSecurityPolicy,
+		ReferrerPolicy:        conf.ReferrerPolicy,
+		PermissionsPolicy:     conf.PermissionsPolicy,
+		Version:               version,
+	}, s.cache.Handler(h))
+
+	debug.Store(conf.Debug)
+	s.current.Store(&h)
+
+	// templates are parsed again on every request in dev mode, so are the responses
+	var limit int64
+	if !conf.DevMode {
+		limit = int64(conf.ResponseCacheMB) << 20
+	}
+	s.cache.SetLimit(limit)
+	s.cache.Purge()
+
+	return nil
+}
+
+func newSiteHandler(conf *config.Config) (http.Handler, error) {
+	key, err := config.ParseKey(conf.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	addr, err := adnlAddress(key)
+	if err != nil {
+		return nil, err
+	}
+	info := render.Site{Address: addr + ".adnl", Version: version}
+
+	rt, err := newRouter(conf, info)
+	if err != nil {
+		return nil, err
+	}
+
+	var h http.Handler = rt
+	if conf.CanonicalRedirect && conf.DefaultHost != "" {
+		h = router.Canonical(conf.DefaultHost, h)
+	}
+
+	rules, err := httpcache.ParseRules(conf.CacheControl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CACHE_CONTROL: %w", err)
+	}
+
+	return compress.Handler(httpcache.CacheControl(rules, h)), nil
+}
+
+// newSite serves the templates, blog and static files of contentDir over the embedded site
+func newSite(conf *config.Config, contentDir string, info render.Site) (http.Handler, *render.Set, error) {
+	files := content.New(contentDir, site.Templates, site.Static)
+	// only the embedded site is served when the directory does not exist yet
+	if files.HasDir() {
+		log.Println("serving content from", contentDir)
+	}
+
+	// every template is parsed here, so a broken one fails the start or the reload
+	set, err := render.NewSet(files, info, conf.DevMode, blog.Funcs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse templates: %w", err)
+	}
+
+	mx := http.NewServeMux()
+	mx.Handle("/", serveTemplate(set))
+	mx.Handle(blog.Prefix, blog.NewHandler(files, set))
+
+	// compressed once here, the content directory is compressed again on reload
+	public := content.NoHidden(files)
+	static, err := compress.NewStatic(public, "static", http.FileServer(http.FS(public)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to compress static files: %w", err)
+	}
+	tagged, err := httpcache.NewFiles(public, "static", static)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to hash static files: %w", err)
+	}
+	mx.Handle("/static/", neuter(tagged))
+
+	// media files are large, they are neither hashed nor compressed ahead, ranges let players seek
+	if st, err := os.Stat(conf.MediaDir); conf.MediaDir != "" && err == nil && st.IsDir() {
+		log.Println("serving media from", conf.MediaDir)
+		mx.Handle("/media/", neuter(http.StripPrefix("/media", http.FileServer(http.FS(content.NoHidden(content.Dir(conf.MediaDir)))))))
+	}
+
+	return mx, set, nil
+}
+
+// watchConfig reloads the config on SIGHUP or options file changes and applies it to the site
+func watchConfig(ctx context.Context, args []string, conf *config.Config, handler *siteHandler) {
+	config.NewWatcher(args, version, conf, handler.apply).Run(ctx)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ad/ton-site-ha/config"
+)
+
+// rotation describes the previous site address which is kept alive while DNS records are updated
+type rotation struct {
+	OldAddress string
+	NewAddress string
+	Mode       string
+	Until      time.Time
+}
+
+// handler serves the previous address, either as a copy of the site or redirecting to the new address
+func (r *rotation) handler(next http.Handler) http.Handler {
+	if r.Mode == config.RotationMirror {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Redirect(w, req, "http://"+r.NewAddress+req.URL.RequestURI(), http.StatusFound)
+	})
+}
+
+func (r *rotation) remind() {
+	until := "until PREVIOUS_KEY is removed"
+	if !r.Until.IsZero() {
+		until = "until " + r.Until.Format(time.DateTime) + " (" + time.Until(r.Until).Round(time.Hour).String() + " left)"
+	}
+
+	log.Println("Key rotation: previous address", r.OldAddress, "is served", until+".",
+		"Update the site record of your domain to", r.NewAddress, "before that, `app dns-record` prints the payload")
+}
+
+// remindLoop repeats the reminder daily for the whole overlap period
+func (r *rotation) remindLoop() {
+	for {
+		r.remind()
+
+		wait := 24 * time.Hour
+		if !r.Until.IsZero() {
+			left := time.Until(r.Until)
+			if left <= 0 {
+				log.Println("Key rotation: previous address", r.OldAddress, "is retired, remove the previous key from the options")
+				return
+			}
+			wait = min(wait, left)
+		}
+
+		time.Sleep(wait)
+	}
+}
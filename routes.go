@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ad/ton-site-ha/config"
+	"github.com/ad/ton-site-ha/internal/autoindex"
+	"github.com/ad/ton-site-ha/internal/blog"
+	"github.com/ad/ton-site-ha/internal/content"
+	"github.com/ad/ton-site-ha/internal/render"
+	"github.com/ad/ton-site-ha/internal/respcache"
+	"github.com/ad/ton-site-ha/internal/router"
+	"github.com/ad/ton-site-ha/site"
+)
+
+// defaultRoutes serve the whole site when ROUTES is empty
+var defaultRoutes = config.Routes{{Path: "/", Type: config.RouteSite}}
+
+// newRouter builds the handlers of ROUTES, a route that can't be served fails the start or the reload
+func newRouter(conf *config.Config, info render.Site) (*router.Router, error) {
+	routes := conf.Routes
+	if len(routes) == 0 {
+		routes = defaultRoutes
+	}
+
+	// listings render with the layout of CONTENT_DIR, parsed for the first listed route
+	var listSet *render.Set
+
+	rt := &router.Router{}
+	for i, route := range routes {
+		r := router.Route{Path: route.Path, Host: route.Host}
+
+		var err error
+		switch route.Type {
+		case config.RouteSite:
+			var set *render.Set
+			if route.Target == "" && len(conf.Hosts) > 0 {
+				r.Handler, set, err = newHostSites(conf, info)
+			} else {
+				r.Handler, set, err = newSite(conf, siteDir(conf, route), info)
+			}
+			if err == nil && rt.NotFound == nil {
+				// requests no route takes get the 404 page of the first site
+				rt.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					set.Error(w, r, http.StatusNotFound)
+				})
+			}
+		case config.RouteStatic:
+			var files fs.FS
+			if files, err = staticDir(conf, route.Target); err != nil {
+				break
+			}
+			if !route.ShowHidden {
+				files = content.NoHidden(files)
+			}
+			if !route.Autoindex {
+				r.Handler = router.Static(route.Path, files)
+				break
+			}
+			if listSet == nil {
+				files := content.New(conf.ContentDir, site.Templates, site.Static)
+				if listSet, err = render.NewSet(files, info, conf.DevMode, blog.Funcs); err != nil {
+					break
+				}
+			}
+			r.Handler = autoindex.New(route.Path, files, listSet, autoindex.Options{ShowHidden: route.ShowHidden, Index: route.Index})
+		case config.RouteProxy:
+			var target *url.URL
+			if target, err = url.Parse(route.Target); err == nil {
+				// the upstream may answer each visitor differently, only its public responses are shared
+				timeout := router.DefaultProxyTimeout
+				if route.Timeout > 0 {
+					timeout = time.Duration(route.Timeout) * time.Second
+				}
+				r.Handler = respcache.SharedOnly(router.Proxy(target, timeout))
+			}
+		case config.RouteRedirect:
+			r.Handler = router.Redirect(route.Target, statusOr(route.Status, http.StatusFound))
+		case config.RouteRewrite:
+			r.Rewrite, err = router.Rewriter(route.Match, route.Target)
+		case config.RouteRespond:
+			r.Handler = router.Respond(statusOr(route.Status, http.StatusOK), route.Body)
+		default:
+			err = fmt.Errorf("unknown route type %q", route.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ROUTES[%d] %s %s: %w", i, route.Type, route.Path, err)
+		}
+
+		rt.Routes = append(rt.Routes, r)
+	}
+
+	return rt, nil
+}
+
+// newHostSites serves the site of the request's host from HOSTS, the .adnl address and
+// hosts not listed get DEFAULT_HOST or, without it, CONTENT_DIR
+func newHostSites(conf *config.Config, info render.Site) (http.Handler, *render.Set, error) {
+	hosts := &router.Router{}
+
+	var fallback http.Handler
+	var fallbackSet *render.Set
+	for i, h := range conf.Hosts {
+		if st, err := os.Stat(h.ContentDir); err != nil || !st.IsDir() {
+			log.Printf("content dir %s of %s not found, serving the built-in site", h.ContentDir, h.Host)
+		}
+
+		handler, set, err := newSite(conf, h.ContentDir, info)
+		if err != nil {
+			return nil, nil, fmt.Errorf("HOSTS[%d] %s: %w", i, h.Host, err)
+		}
+		hosts.Routes = append(hosts.Routes, router.Route{Path: "/", Host: h.Host, Handler: handler})
+
+		if strings.EqualFold(h.Host, conf.DefaultHost) {
+			fallback, fallbackSet = handler, set
+		}
+	}
+
+	if fallback == nil {
+		var err error
+		if fallback, fallbackSet, err = newSite(conf, conf.ContentDir, info); err != nil {
+			return nil, nil, err
+		}
+	}
+	hosts.NotFound = fallback
+
+	return hosts, fallbackSet, nil
+}
+
+// siteDir is the content directory of a site route, CONTENT_DIR unless it has its own
+func siteDir(conf *config.Config, route config.Route) string {
+	if route.Target != "" {
+		return route.Target
+	}
+
+	return conf.ContentDir
+}
+
+// staticDir opens an absolute directory on disk, or a directory of the site,
+// content directory over the embedded files, for a relative one
+func staticDir(conf *config.Config, dir string) (fs.FS, error) {
+	if filepath.IsAbs(dir) {
+		if st, err := os.Stat(dir); err != nil || !st.IsDir() {
+			return nil, fmt.Errorf("directory %s not found", dir)
+		}
+		return content.Dir(dir), nil
+	}
+
+	dir = path.Clean(dir)
+	files := content.New(conf.ContentDir, site.Templates, site.Static)
+	if st, err := fs.Stat(files, dir); err != nil || !st.IsDir() {
+		return nil, fmt.Errorf("directory %s not found in the site", dir)
+	}
+
+	return fs.Sub(files, dir)
+}
+
+func statusOr(status, fallback int) int {
+	if status == 0 {
+		return fallback
+	}
+
+	return status
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ad/ton-site-ha/config"
+	"github.com/ad/ton-site-ha/internal/render"
+	"github.com/ad/ton-site-ha/internal/secure"
+)
+
+func TestTraversal(t *testing.T) {
+	root := t.TempDir()
+	files := filepath.Join(root, "files")
+	if err := os.MkdirAll(filepath.Join(files, "docs"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{
+		filepath.Join(root, "secret.txt"):                     "private key",
+		filepath.Join(files, "docs", "guide.txt"):             "guide",
+		filepath.Join(files, ".env"):                          "private key",
+		filepath.Join(files, ".git", "config"):                "private key",
+		filepath.Join(root, "content", "static", ".env"):      "private key",
+		filepath.Join(root, "content", "static", "style.css"): "body {}",
+		filepath.Join(root, "media", ".env"):                  "private key",
+		filepath.Join(root, "media", "clip.mp4"):              "clip",
+	} {
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conf := config.Defaults("test")
+	conf.ContentDir = filepath.Join(root, "content")
+	conf.MediaDir = filepath.Join(root, "media")
+	conf.Routes = config.Routes{
+		{Path: "/files/", Type: config.RouteStatic, Target: files},
+		{Path: "/", Type: config.RouteSite},
+	}
+
+	rt, err := newRouter(conf, render.Site{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := secure.Handler(secure.Headers{Version: "v1.2.3"}, rt)
+
+	cases := map[string]int{
+		"/":                                    http.StatusOK,
+		"/files/docs/guide.txt":                http.StatusOK,
+		"/files/../secret.txt":                 http.StatusMovedPermanently,
+		"/files/%2e%2e/secret.txt":             http.StatusBadRequest,
+		"/files/docs/%2E%2E/%2e%2e/secret.txt": http.StatusBadRequest,
+		"/files/..%2fsecret.txt":               http.StatusBadRequest,
+		"/files/..%5csecret.txt":               http.StatusBadRequest,
+		"/files/docs/guide.txt%00":             http.StatusBadRequest,
+		"/../../etc/passwd":                    http.StatusBadRequest,
+		"/%2e%2e/%2e%2e/etc/passwd":            http.StatusBadRequest,
+		"/..%2f..%2fetc%2fpasswd":              http.StatusBadRequest,
+		"/index.html%00.txt":                   http.StatusBadRequest,
+		"/index.html":                          http.StatusOK,
+		"/errors/404.html":                     http.StatusNotFound,
+		"/static/../../go.mod":                 http.StatusBadRequest,
+		"/files/.env":                          http.StatusNotFound,
+		"/files/.git/config":                   http.StatusNotFound,
+		"/static/style.css":                    http.StatusOK,
+		"/static/.env":                         http.StatusNotFound,
+		"/media/clip.mp4":                      http.StatusOK,
+		"/media/.env":                          http.StatusNotFound,
+	}
+
+	for target, code := range cases {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://site.adnl"+target, nil))
+		if w.Code != code {
+			t.Errorf("%s: expected %d, but got %d", target, code, w.Code)
+		}
+		if strings.Contains(w.Body.String(), "private key") {
+			t.Errorf("%s: the file outside the route was served", target)
+		}
+		if got := w.Header().Get(secure.VersionHeader); got != "Commit: v1.2.3" {
+			t.Errorf("%s: expected the version header, but got %q", target, got)
+		}
+	}
+}
@@ -5,5 +5,8 @@ import "embed"
 //go:embed static/*
 var Static embed.FS
 
-//go:embed templates/*.html
+//go:embed templates
 var Templates embed.FS
+
+//go:embed status/*.html
+var Status embed.FS
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/ad/ton-site-ha/internal/respcache"
+	"github.com/ad/ton-site-ha/site"
+)
+
+// serveStatus runs the local server of the status page and of the metrics,
+// one of them can be nil
+func serveStatus(listenAddr string, page http.Handler, cache *respcache.Cache) {
+	log.Println("Status server is available on", listenAddr)
+
+	if err := http.ListenAndServe(listenAddr, statusMux(page, cache)); err != nil {
+		log.Println("status server stopped:", err.Error())
+	}
+}
+
+// statusMux serves the metrics of the response cache under /metrics if cache is not nil,
+// and the status page at / if page is not nil
+func statusMux(page http.Handler, cache *respcache.Cache) *http.ServeMux {
+	mx := http.NewServeMux()
+	if cache != nil {
+		mx.HandleFunc("/metrics", serveMetrics(cache))
+	}
+	if page != nil {
+		mx.Handle("/", page)
+	}
+
+	return mx
+}
+
+// statusPage renders the address, the site record payload and, with a domain NFT, the wallet link
+func statusPage(rec *dnsRecord, rot *rotation) http.Handler {
+	tmpl := template.Must(template.ParseFS(site.Status, "status/status.html"))
+
+	data := struct {
+		Version  string
+		DNS      *dnsRecord
+		Link     template.URL
+		QR       template.URL
+		Rotation *rotation
+	}{
+		Version:  version,
+		DNS:      rec,
+		Rotation: rot,
+	}
+
+	if rec.Link != "" {
+		// html/template only trusts http, https and mailto links, ton:// is built by us
+		data.Link = template.URL(rec.Link)
+
+		png, err := rec.QR()
+		if err != nil {
+			log.Println("failed to render QR code:", err.Error())
+		} else {
+			data.QR = template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(png))
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+
+		if err := tmpl.Execute(w, data); err != nil {
+			log.Print(err.Error())
+		}
+	})
+}
+
+// serveMetrics reports the response cache in the Prometheus text format
+func serveMetrics(cache *respcache.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		st := cache.Stats()
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		metric := func(name, kind, help string, value any) {
+			fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, kind, name, value)
+		}
+
+		metric("ton_site_response_cache_hits_total", "counter", "Responses answered from the cache.", st.Hits)
+		metric("ton_site_response_cache_misses_total", "counter", "Responses rendered because they were not cached.", st.Misses)
+		metric("ton_site_response_cache_hit_ratio", "gauge", "Share of cacheable requests answered from the cache.", st.HitRatio())
+		metric("ton_site_response_cache_entries", "gauge", "Responses in the cache.", st.Entries)
+		metric("ton_site_response_cache_size_bytes", "gauge", "Memory used by cached responses.", st.Size)
+		metric("ton_site_response_cache_limit_bytes", "gauge", "Memory the cache may use, RESPONSE_CACHE_MB.", st.Limit)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ad/ton-site-ha/internal/respcache"
+)
+
+func TestStatusPage(t *testing.T) {
+	rec, err := newDNSRecord(bytes.Repeat([]byte{1}, 32), "EQBvW8Z5huBkMJYdnfAEM5JqTNkuWX3diqYENkWsIL0XggGG")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	statusPage(rec, nil).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	body := w.Body.String()
+	if want := `href="` + html.EscapeString(rec.Link) + `"`; !strings.Contains(body, want) {
+		t.Errorf("Expected the wallet link %s in %s", want, body)
+	}
+	if strings.Contains(body, "ZgotmplZ") {
+		t.Error("Expected no link to be filtered out")
+	}
+	if !strings.Contains(body, `src="data:image/png;base64,`) {
+		t.Error("Expected the QR code")
+	}
+
+	rec.Link = ""
+	w = httptest.NewRecorder()
+	statusPage(rec, nil).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(w.Body.String(), "Set <code>DOMAIN_NFT_ADDRESS</code>") {
+		t.Errorf("Expected the hint without a domain NFT, but got %s", w.Body.String())
+	}
+}
+
+func TestStatusMuxMetrics(t *testing.T) {
+	mx := statusMux(nil, respcache.New(1<<20))
+
+	w := httptest.NewRecorder()
+	mx.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "ton_site_response_cache_limit_bytes 1048576") {
+		t.Errorf("Expected the metrics without a status page, but got %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	mx.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected no status page, but got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	statusMux(http.NotFoundHandler(), nil).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected no metrics without METRICS, but got %d", w.Code)
+	}
+}
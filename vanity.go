@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/ad/ton-site-ha/config"
+	"github.com/ad/ton-site-ha/internal/vanity"
+)
+
+// vanityCommand searches for a key whose address starts with or contains the given pattern
+func vanityCommand(args []string) error {
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	contains := flags.Bool("contains", false, "match the pattern anywhere in the address instead of its start")
+	threads := flags.Int("threads", runtime.NumCPU(), "number of CPU cores to use")
+	out := flags.String("out", "vanity.key", "file to write the found key seed to")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New("usage: vanity [-contains] [-threads N] [-out file] pattern")
+	}
+
+	p := vanity.Pattern{Text: flags.Arg(0), Contains: *contains}
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(*out); err == nil {
+		return fmt.Errorf("%s already exists", *out)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	log.Printf("searching for %q on %d threads, ~%.0f keys to try on average", p.Text, *threads, p.Attempts())
+
+	var tried atomic.Uint64
+	started := time.Now()
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(5 * time.Second):
+			}
+
+			n := tried.Load()
+			rate := float64(n) / time.Since(started).Seconds()
+			eta := time.Duration(p.Attempts() / rate * float64(time.Second))
+			log.Printf("tried %d keys, %.0f keys/s, expected time %s", n, rate, eta.Round(time.Second))
+		}
+	}()
+
+	res, err := vanity.Search(ctx, p, *threads, &tried)
+	if err != nil {
+		return fmt.Errorf("search stopped after %d keys: %w", tried.Load(), err)
+	}
+
+	if err := config.SaveKeyStore(*out, hex.EncodeToString(res.Seed), ""); err != nil {
+		return err
+	}
+
+	log.Printf("found after %d keys in %s", tried.Load(), time.Since(started).Round(time.Second))
+	fmt.Println("address:", res.Address+".adnl")
+	fmt.Println("key seed is written to", *out+", use it as KEY_FILE or `app key import <", *out+"`")
+
+	return nil
+}